@@ -0,0 +1,35 @@
+// Command reaper removes testclusters-go clusters which were leaked by killed
+// test runs on this host.
+//
+// Usage:
+//
+//	go run github.com/test-clusters/testclusters-go/cmd/reaper -older-than 10m
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/test-clusters/testclusters-go/pkg/cluster"
+)
+
+func main() {
+	olderThan := flag.Duration("older-than", 5*time.Minute, "only remove clusters that were created before this duration")
+	flag.Parse()
+
+	reaped, err := cluster.ReapStale(context.Background(), *olderThan)
+	for _, name := range reaped {
+		fmt.Println("removed stale cluster", name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	if len(reaped) == 0 {
+		fmt.Println("no stale clusters found")
+	}
+}
@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// Backend names an engine which provides clusters, see Opts.Backend.
+type Backend string
+
+// BackendK3d runs the cluster with k3d in the local container runtime.
+const BackendK3d Backend = "k3d"
+
+// BackendFactory creates a new cluster of a backend with the given options.
+type BackendFactory func(ctx context.Context, opts Opts) (Cluster, error)
+
+var (
+	backendsMutex sync.RWMutex
+	backends      = map[Backend]BackendFactory{
+		BackendK3d: newK3dBackendCluster,
+	}
+)
+
+func newK3dBackendCluster(ctx context.Context, opts Opts) (Cluster, error) {
+	cluster, err := setupCluster(ctx, opts)
+	if err != nil {
+		// avoid a non-nil interface with a nil cluster
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// RegisterBackend makes a backend available for Opts.Backend so that alternative
+// engines can provide clusters. An already registered backend is replaced.
+func RegisterBackend(backend Backend, factory BackendFactory) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+
+	backends[backend] = factory
+}
+
+func backendFactory(backend Backend) (BackendFactory, error) {
+	if backend == "" {
+		backend = BackendK3d
+	}
+
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
+
+	factory, ok := backends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster backend %s (registered backends: %v)", backend, registeredBackends())
+	}
+	return factory, nil
+}
+
+func registeredBackends() []string {
+	var names []string
+	for backend := range backends {
+		names = append(names, string(backend))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewCluster creates a new cluster with the backend selected by Opts.Backend.
+// Unlike NewK3dClusterWithOpts, tests only depend on the Cluster interface and
+// thus do not care which engine provides the cluster. Start-up failures fail
+// the test immediately.
+func NewCluster(t testing.TB, opts Opts) Cluster {
+	t.Helper()
+
+	cluster, err := NewClusterE(t, opts)
+	if err != nil {
+		t.Fatalf("testcluster-go: Unexpected error during test setup: %s", err.Error())
+	}
+
+	return cluster
+}
+
+// NewClusterE creates like NewCluster a new cluster but returns start-up failures
+// instead of failing the test. The cluster will be terminated once the test
+// finishes.
+func NewClusterE(t testing.TB, opts Opts) (Cluster, error) {
+	t.Helper()
+
+	opts.testName = t.Name()
+	cluster, err := CreateCluster(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	registerTearDown(t, cluster)
+
+	return cluster, nil
+}
+
+// CreateCluster creates like NewCluster a new cluster but does not depend on the
+// testing package so that other test frameworks (like Ginkgo) may use it. The
+// caller is responsible to Terminate the cluster.
+func CreateCluster(ctx context.Context, opts Opts) (Cluster, error) {
+	factory, err := backendFactory(opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+
+	return factory(ctx, opts)
+}
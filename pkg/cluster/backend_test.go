@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCluster(t *testing.T) {
+	t.Run("should use registered backend", func(t *testing.T) {
+		const testBackend Backend = "test"
+		expected := &K3dCluster{ClusterName: "from-test-backend"}
+		var receivedOpts Opts
+		RegisterBackend(testBackend, func(ctx context.Context, opts Opts) (Cluster, error) {
+			receivedOpts = opts
+			return expected, nil
+		})
+		t.Cleanup(func() {
+			backendsMutex.Lock()
+			delete(backends, testBackend)
+			backendsMutex.Unlock()
+		})
+
+		actual, err := CreateCluster(testCtx, Opts{Backend: testBackend, ClusterNamePrefix: "prefix"})
+
+		require.NoError(t, err)
+		assert.Same(t, expected, actual)
+		assert.Equal(t, "prefix", receivedOpts.ClusterNamePrefix)
+	})
+	t.Run("should fail on unknown backend", func(t *testing.T) {
+		_, err := CreateCluster(testCtx, Opts{Backend: "kind"})
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "unknown cluster backend kind")
+		assert.ErrorContains(t, err, "k3d")
+	})
+}
+
+func Test_backendFactory(t *testing.T) {
+	t.Run("should default to k3d", func(t *testing.T) {
+		factory, err := backendFactory("")
+
+		require.NoError(t, err)
+		assert.NotNil(t, factory)
+	})
+}
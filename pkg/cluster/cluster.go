@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -38,6 +40,9 @@ import (
 const appName = "k8s-containers"
 const DefaultNamespace = "default"
 
+// creatorLabel marks K8s resources which were created by testclusters-go.
+const creatorLabel = "k3s.creator"
+
 // k3s versions
 // warning: k3s versions are tagged with a `+` separator before `k3s1`, but k3s images use `-`.
 const (
@@ -56,7 +61,7 @@ const defaultPrefix = "tc-"
 //   - the defaultPrefix
 //   - 8 alphanumeric characters to identify containers of the same cluster
 //   - the pod name
-const restrictedContainerNameChars = `[a-zA-Z0-9][a-zA-Z0-9_.-]{1..37}`
+const restrictedContainerNameChars = `[a-zA-Z0-9][a-zA-Z0-9_.-]{1,37}`
 
 var restrictedContainerNamePattern = regexp.MustCompile(`^` + restrictedContainerNameChars + `$`)
 
@@ -74,11 +79,24 @@ const (
 	Debug
 )
 
-type testcluster interface {
+// Cluster provides a K8s cluster to tests regardless of the backend which runs the cluster.
+type Cluster interface {
+	// ClientSet returns a K8s clientset which allows to interoperate with the cluster K8s API.
+	ClientSet() (kubernetes.Interface, error)
+	// RestConfig returns the client configuration to build further K8s clients.
+	RestConfig() (*rest.Config, error)
+	// KubeConfig returns the kube config which grants access to the cluster.
+	KubeConfig() (*api.Config, error)
+	// CtlKube returns a YamlApplier which applies resources with the given field manager.
+	CtlKube(fieldManager string) (*YamlApplier, error)
+	// Lookout creates a new Lookout that interacts with the cluster.
+	Lookout(t testing.TB) (*Lookout, error)
 	// Terminate stops all workloads and reclaims spent computational resources.
 	Terminate(ctx context.Context) error
 }
 
+var _ Cluster = &K3dCluster{}
+
 // Opts allows customizing the K3d cluster's creation and operation.
 type Opts struct {
 	// ClusterNamePrefix will be used to name the cluster so developers can discover
@@ -94,6 +112,74 @@ type Opts struct {
 	// Defaults to empty string.
 	// See health.KubeletEvictionFsByPercentage for a helper function
 	NodeConditionEvictionHardArg string
+	// K3sVersion selects the K3s version of the cluster nodes. It may be one of
+	// KnownK3sVersions or a custom image reference with a tag, like
+	// "rancher/k3s:v1.27.6-k3s1".
+	// Defaults to DefaultK3sVersion.
+	K3sVersion string
+	// Servers sets the number of K3s server nodes.
+	// Defaults to 1.
+	Servers int
+	// Agents sets the number of K3s agent nodes.
+	// Defaults to 0.
+	Agents int
+	// NodeLabels adds K8s labels to the nodes, f. i. to describe topology zones.
+	NodeLabels []NodeLabel
+	// NodeTaints adds K8s taints to the nodes.
+	NodeTaints []NodeTaint
+	// SimpleConfig allows to customize the k3d cluster configuration (f. i. volumes, ports, env or k3s args)
+	// beyond these options. Its non-empty values replace the configuration testclusters-go would use otherwise
+	// while lists are joined. The cluster name and API port cannot be changed.
+	// Defaults to nil.
+	SimpleConfig *v1alpha5.SimpleConfig
+	// SimpleConfigFile contains the path to a k3d configuration file which will be merged like SimpleConfig.
+	// Unlike with SimpleConfig, values which are set in the file win even if they are empty, f. i. `agents: 0`.
+	// SimpleConfig takes precedence over the file.
+	// Defaults to the empty string.
+	SimpleConfigFile string
+	// PreloadImages contains images from the local container runtime which will be imported into the cluster
+	// during start-up. See also K3dCluster.ImportImages.
+	PreloadImages []string
+	// Registry configures the image registry which will be created along with the cluster.
+	// Defaults to nil which creates a registry on a random free host port that acts as pull-through cache for
+	// Docker Hub.
+	Registry *RegistryOpts
+	// DisableRegistry creates the cluster without image registry. It must not be combined with Registry.
+	// Defaults to false.
+	DisableRegistry bool
+	// Ports publishes cluster ports on the host. See also K3dCluster.HostURL.
+	Ports []PortMapping
+	// Backend selects the engine which provides the cluster for NewCluster and CreateCluster. Options which do
+	// not apply to a backend are ignored by it.
+	// Defaults to BackendK3d.
+	Backend Backend
+	// KubeConfigPath selects the kube config of BackendExisting.
+	// Defaults to the empty string which loads the kube config like kubectl does, f. i. from KUBECONFIG.
+	KubeConfigPath string
+	// KubeContext selects the kube config context of BackendExisting.
+	// Defaults to the current context of the kube config.
+	KubeContext string
+	// Runtime selects the container runtime which runs the k3d cluster. Podman is reached through its
+	// Docker-compatible API socket. Setup problems like missing socket permissions or missing cgroup delegation for
+	// rootless Podman are reported before the cluster is created.
+	// Defaults to auto-detection: DOCKER_HOST, the Docker socket, the rootless and then the rootful Podman socket.
+	Runtime ContainerRuntime
+	// Reuse keeps the cluster alive after the test so that following test runs can reuse it instead of
+	// creating a new one. This saves start-up time during local development. Reused clusters are found by a
+	// stable name which is derived from ClusterNamePrefix or the test package. The options of a reused cluster
+	// are not compared against the current options.
+	// Defaults to false, or true if the environment variable TESTCLUSTERS_REUSE is set to true.
+	Reuse bool
+	// KeepOnFailure keeps the cluster alive if the test failed so that it can be inspected afterwards. The kube
+	// config of a kept cluster is written next to the diagnostics of the failed test, and the test output shows how
+	// to inspect and delete the cluster. Kept clusters are not reaped by ReapStale.
+	// Defaults to false, or true if the environment variable TESTCLUSTERS_KEEP_ON_FAILURE is set to true.
+	KeepOnFailure bool
+
+	// testName contains the name of the test which owns the cluster.
+	testName string
+	// reusable marks clusters which will be reused by following test runs.
+	reusable bool
 }
 
 // K3dCluster abstracts the cluster management during developer tests.
@@ -105,12 +191,19 @@ type K3dCluster struct {
 	ClusterName         string
 	AdminServiceAccount string
 	clientSet           kubernetes.Interface
+	// reused marks clusters which outlive the test, see Opts.Reuse.
+	reused bool
+	// keepOnFailure marks clusters which outlive failed tests, see Opts.KeepOnFailure.
+	keepOnFailure bool
+	startupReport StartupReport
+	snapshotMutex sync.Mutex
+	snapshots     map[SnapshotID]datastoreSnapshot
 }
 
 // NewK3dCluster creates a completely new cluster within the provided container
 // engine and default values. This method is the usual entry point of a test with
 // testclusters-go.
-func NewK3dCluster(t *testing.T) *K3dCluster {
+func NewK3dCluster(t testing.TB) *K3dCluster {
 	defaultOpts := Opts{
 		ClusterNamePrefix:            "",
 		LogLevel:                     Warning,
@@ -120,40 +213,50 @@ func NewK3dCluster(t *testing.T) *K3dCluster {
 }
 
 // NewK3dClusterWithOpts creates like NewK3dCluster a new cluster but with more control over customization.
-func NewK3dClusterWithOpts(t *testing.T, opts Opts) *K3dCluster {
-	cluster := mustSetupCluster(t, opts)
-	registerTearDown(t, cluster)
+// Start-up failures fail the test immediately.
+func NewK3dClusterWithOpts(t testing.TB, opts Opts) *K3dCluster {
+	t.Helper()
+
+	cluster, err := NewK3dClusterWithOptsE(t, opts)
+	if err != nil {
+		t.Fatalf("testcluster-go: Unexpected error during test setup: %s", err.Error())
+	}
 
 	return cluster
 }
 
-func mustSetupCluster(t *testing.T, opts Opts) *K3dCluster {
-	l.Log().Info("testcluster-go: Creating cluster")
-	var err error
-	ctx := context.Background()
+// NewK3dClusterWithOptsE creates like NewK3dClusterWithOpts a new cluster but
+// returns start-up failures instead of failing the test. The cluster will be
+// terminated once the test finishes.
+func NewK3dClusterWithOptsE(t testing.TB, opts Opts) (*K3dCluster, error) {
+	t.Helper()
 
-	clusterNamePrefix, err := validateClusterNamePrefix(opts.ClusterNamePrefix)
+	opts.testName = t.Name()
+	cluster, err := setupCluster(context.Background(), opts)
 	if err != nil {
-		errMsg := fmt.Sprintf("testcluster-go: Invalid cluster name prefix found: %s", err.Error())
-		l.Log().Error(errMsg)
-		_ = panicAtStartError(ctx, nil, err)
+		return nil, err
+	}
+	registerTearDown(t, cluster)
+
+	return cluster, nil
+}
+
+func setupCluster(ctx context.Context, opts Opts) (*K3dCluster, error) {
+	if reuseEnabled(opts) {
+		return reuseOrCreateK3dCluster(ctx, opts)
 	}
-	opts.ClusterNamePrefix = clusterNamePrefix
+
+	l.Log().Info("testcluster-go: Creating cluster")
 
 	cluster, err := CreateK3dCluster(ctx, opts)
 	if err != nil {
 		if strings.Contains(err.Error(), "port is already allocated") {
 			l.Log().Error("Port is already allocated. Was another test-cluster running not properly cleaned up? The clean-up instruction might help.")
-			_ = panicAtStartError(ctx, cluster, err)
-			return nil
 		}
-
-		t.Errorf("Unexpected error during test setup: %s\n", err)
-		_ = panicAtStartError(ctx, cluster, err)
-		return nil
+		return nil, err
 	}
 
-	return cluster
+	return cluster, nil
 }
 
 func validateClusterNamePrefix(prefix string) (string, error) {
@@ -165,7 +268,7 @@ func validateClusterNamePrefix(prefix string) (string, error) {
 	totalPrefix := defaultPrefix + prefix
 	valid := restrictedContainerNamePattern.MatchString(totalPrefix)
 
-	if valid {
+	if valid && len(validation.IsDNS1123Label(totalPrefix)) == 0 {
 		return totalPrefix, nil
 	}
 
@@ -183,13 +286,25 @@ func generatePseudoPrefix(length int) string {
 	return string(b)
 }
 
-func registerTearDown(t *testing.T, cluster *K3dCluster) {
-	if cluster == nil || cluster.clusterConfig == nil {
+func registerTearDown(t testing.TB, cluster Cluster) {
+	k3dCluster, isK3d := cluster.(*K3dCluster)
+	if cluster == nil || isK3d && (k3dCluster == nil || k3dCluster.clusterConfig == nil) {
 		t.Errorf("testcluster-go: No cluster or cluster config was found for tear down registration.")
 		return
 	}
 
 	t.Cleanup(func() {
+		dumpDiagnosticsOnFailure(t, cluster, "")
+
+		if isK3d && k3dCluster.reused {
+			l.Log().Infof("testcluster-go: Keeping reusable cluster %s after test", k3dCluster.ClusterName)
+			return
+		}
+		if keepsOnFailure(t, cluster) {
+			keepFailedCluster(t, k3dCluster)
+			return
+		}
+
 		l.Log().Debug("testcluster-go: Terminating cluster during test tear down")
 
 		err := cluster.Terminate(context.Background())
@@ -203,18 +318,45 @@ func registerTearDown(t *testing.T, cluster *K3dCluster) {
 }
 
 func createClusterConfig(ctx context.Context, clusterName string, opts Opts) (*v1alpha5.ClusterConfig, error) {
+	image, err := k3sImage(opts.K3sVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, agents, err := nodeCounts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLabels, err := k3sNodeLabels(opts.NodeLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeTaintArgs, err := k3sNodeTaintArgs(opts.NodeTaints)
+	if err != nil {
+		return nil, err
+	}
+
 	freeHostPort, err := freeport.GetFreePort()
 	if err != nil {
 		return nil, fmt.Errorf("could not find free port for port-forward: %w", err)
 	}
 
-	//TODO fix hardcoding to image reg server. It seems it may be used by different clusters but the port must be configurable.
-	k3sRegistryYaml := `
-my.company.registry":
-  endpoint:
-  - http://my.company.registry:5000
-`
-	const nodeFilter = "server:*"
+	registryOpts, err := registryOptsOf(opts)
+	if err != nil {
+		return nil, err
+	}
+	registries, err := createRegistryConfig(clusterName, registryOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	ports, err := createPortConfig(opts.Ports)
+	if err != nil {
+		return nil, err
+	}
+
 	simpleConfig := v1alpha5.SimpleConfig{
 		TypeMeta: configTypes.TypeMeta{
 			Kind:       "Simple",
@@ -223,41 +365,36 @@ my.company.registry":
 		ObjectMeta: configTypes.ObjectMeta{
 			Name: clusterName,
 		},
-		Image:   fmt.Sprintf("%s:%s", k3dTypes.DefaultK3sImageRepo, K3sVersion1_28),
-		Servers: 1,
-		Agents:  0,
+		Image:   image,
+		Servers: servers,
+		Agents:  agents,
 		Options: v1alpha5.SimpleConfigOptions{
 			K3dOptions: v1alpha5.SimpleConfigOptionsK3d{
 				Wait:    true,
 				Timeout: 60 * time.Second,
 			},
 			K3sOptions: v1alpha5.SimpleConfigOptionsK3s{
-				ExtraArgs: []v1alpha5.K3sArgWithNodeFilters{
+				ExtraArgs: append([]v1alpha5.K3sArgWithNodeFilters{
 					{ // nodeFilters settings may correspond with the number of servers and agents above
 						// TODO extract with proper naming because here goes some magic
 						Arg:         "--kubelet-arg=eviction-hard=" + opts.NodeConditionEvictionHardArg,
-						NodeFilters: []string{nodeFilter},
+						NodeFilters: allNodesFilter,
 					},
-				}},
-		},
-		// allows unpublished images-under-test to be used in the cluster
-		Registries: v1alpha5.SimpleConfigRegistries{
-			Create: &v1alpha5.SimpleConfigRegistryCreateConfig{
-				//Name:	fmt.Sprintf("%s-%s-registry", k3dTypes.DefaultObjectNamePrefix, newCluster.Name),
-				// Host:    "0.0.0.0",
-				HostPort: k3dTypes.DefaultRegistryPort, // alternatively the string "random"
-				// Image:    fmt.Sprintf("%s:%s", k3dTypes.DefaultRegistryImageRepo, k3dTypes.DefaultRegistryImageTag),
-				Proxy: k3dTypes.RegistryProxy{
-					RemoteURL: "https://registry-1.docker.io",
-					Username:  "",
-					Password:  "",
-				},
+				}, nodeTaintArgs...),
+				NodeLabels: nodeLabels,
 			},
-			Config: k3sRegistryYaml,
 		},
+		// allows unpublished images-under-test to be used in the cluster
+		Registries: registries,
 		ExposeAPI: v1alpha5.SimpleExposureOpts{
 			HostPort: strconv.Itoa(freeHostPort),
 		},
+		Ports: ports,
+	}
+
+	simpleConfig, err = overlaySimpleConfig(simpleConfig, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := config.ProcessSimpleConfig(&simpleConfig); err != nil {
@@ -269,6 +406,16 @@ my.company.registry":
 		return nil, fmt.Errorf("failed to transform cluster config: %w", err)
 	}
 
+	for key, value := range clusterLabels(opts.testName) {
+		clusterConfig.ClusterCreateOpts.GlobalLabels[key] = value
+	}
+	if opts.reusable {
+		clusterConfig.ClusterCreateOpts.GlobalLabels[labelReuse] = "true"
+	}
+	if keepOnFailureEnabled(opts) {
+		clusterConfig.ClusterCreateOpts.GlobalLabels[labelKeepOnFailure] = "true"
+	}
+
 	l.Log().Debugf("===== used cluster config =====\n%#v\n===== =====", clusterConfig)
 
 	clusterConfig, err = config.ProcessClusterConfig(*clusterConfig)
@@ -288,53 +435,94 @@ my.company.registry":
 }
 
 // CreateK3dCluster creates a completely new K8s cluster with an optional clusterNamePrefix.
-func CreateK3dCluster(ctx context.Context, opts Opts) (cl *K3dCluster, err error) {
-	containerRuntime := runtimes.SelectedRuntime
+//
+// Unlike NewK3dCluster, this function does not depend on the testing package so
+// that other test frameworks (like Ginkgo) may use it. The caller is responsible
+// to Terminate the cluster. On start-up failures the cluster will be terminated
+// before the error is returned.
+func CreateK3dCluster(ctx context.Context, opts Opts) (*K3dCluster, error) {
+	err := prepareContainerRuntime(opts)
+	if err != nil {
+		return nil, fmt.Errorf("container runtime preflight failed: %w", err)
+	}
 
-	clusterName := naming.MustGenerateK8sName(opts.ClusterNamePrefix)
+	clusterNamePrefix, err := validateClusterNamePrefix(opts.ClusterNamePrefix)
+	if err != nil {
+		l.Log().Errorf("testcluster-go: Invalid cluster name prefix found: %s", err.Error())
+		return nil, fmt.Errorf("invalid cluster name prefix found: %w", err)
+	}
+
+	return createK3dCluster(ctx, naming.MustGenerateK8sName(clusterNamePrefix), opts)
+}
+
+func createK3dCluster(ctx context.Context, clusterName string, opts Opts) (cl *K3dCluster, err error) {
+	timer := newStartupTimer(time.Now)
+	containerRuntime := runtimes.SelectedRuntime
 	cl = &K3dCluster{
 		containerRuntime: containerRuntime,
 		ClusterName:      clusterName,
+		keepOnFailure:    keepOnFailureEnabled(opts),
 	}
 
 	cl.clusterConfig, err = createClusterConfig(ctx, clusterName, opts)
 	if err != nil {
 		return nil, err
 	}
+	timer.done(PhaseConfig)
+
+	err = createImageVolume(ctx, containerRuntime, cl.clusterConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	err = client.ClusterRun(ctx, containerRuntime, cl.clusterConfig)
 	if err != nil {
-		return nil, panicAtStartError(ctx, cl, err)
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to run cluster: %w", err))
+	}
+	timer.done(PhaseClusterRun)
+
+	err = cl.ImportImages(ctx, opts.PreloadImages...)
+	if err != nil {
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to preload images: %w", err))
 	}
+	timer.done(PhaseImageImport)
 
 	cl.kubeConfig, err = client.KubeconfigGet(ctx, containerRuntime, &cl.clusterConfig.Cluster)
 	if err != nil {
-		return nil, panicAtStartError(ctx, cl, err)
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to get kube config: %w", err))
 	}
 	l.Log().Debugf("testcluster-go: ===== retrieved kube config ====\n%#v\n===== =====", cl.kubeConfig)
 
 	err = initializeClientSet(cl)
 	if err != nil {
-		return nil, panicAtStartError(ctx, cl, fmt.Errorf("failed to initialize clientset: %w", err))
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to initialize clientset: %w", err))
 	}
+	timer.done(PhaseKubeConfig)
 
-	sa, err := createDefaultRBACForSA(ctx, cl)
+	sa, err := createDefaultRBACForSA(ctx, cl.clientSet, globalGalacticClusterAdminSuffix)
 	if err != nil {
-		return cl, panicAtStartError(ctx, cl, fmt.Errorf("failed to create default RBAC for SA: %w", err))
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to create default RBAC for SA: %w", err))
 	}
 	cl.AdminServiceAccount = sa
+	timer.done(PhaseRBAC)
 
 	l.Log().Info("testcluster-go: Cluster was successfully created")
 
-	err = cl.waitForDefaultSACreation(ctx)
+	err = waitForDefaultSACreation(ctx, cl.clientSet)
 	if err != nil {
-		return cl, panicAtStartError(ctx, cl, fmt.Errorf("failed to wait for default service account: %w", err))
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to wait for default service account: %w", err))
 	}
+	timer.done(PhaseDefaultServiceAccount)
 
-	err = cl.checkNodeHealth(ctx, NodeHealthCheckOpts{})
+	err = cl.checkNodeHealth(ctx, NodeHealthCheckOpts{ExpectedNodes: k3sNodeCount(cl.clusterConfig)})
 	if err != nil {
-		return cl, panicAtStartError(ctx, cl, fmt.Errorf("failed to check node health: %w", err))
+		return nil, terminateAtStartError(ctx, cl, fmt.Errorf("failed to check node health: %w", err))
 	}
+	timer.done(PhaseNodeHealth)
+
+	cl.startupReport = timer.finish()
+	recordStartup(cl.startupReport)
+	l.Log().Debugf("testcluster-go: Cluster %s started in %s", clusterName, cl.startupReport)
 
 	return cl, nil
 }
@@ -360,35 +548,28 @@ func initializeClientSet(c *K3dCluster) error {
 	return nil
 }
 
-// TODO allow the user to overwrite our ClusterConfig with her own
-// func CreateK3dClusterWithConfig() ...
-
-func createDefaultRBACForSA(ctx context.Context, c *K3dCluster) (string, error) {
-	const globalGalacticClusterAdminSuffix = "ford-prefect"
-
-	clientSet, err := c.ClientSet()
-	if err != nil {
-		return "", err
-	}
+const globalGalacticClusterAdminSuffix = "ford-prefect"
 
+// createDefaultRBACForSA creates a service account with cluster admin
+// permissions whose resource names end with the given suffix.
+func createDefaultRBACForSA(ctx context.Context, clientSet kubernetes.Interface, suffix string) (string, error) {
 	sa := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "sa-" + globalGalacticClusterAdminSuffix,
+			Name:      "sa-" + suffix,
 			Namespace: DefaultNamespace,
-			Labels:    map[string]string{"k3s.creator": appName},
+			Labels:    map[string]string{creatorLabel: appName},
 		},
 	}
 
-	sa, err = clientSet.CoreV1().ServiceAccounts(DefaultNamespace).Create(ctx, sa, metav1.CreateOptions{})
+	sa, err := clientSet.CoreV1().ServiceAccounts(DefaultNamespace).Create(ctx, sa, metav1.CreateOptions{})
 	if err != nil {
 		return "", err
 	}
 
 	clusterRole := &rbacv1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "cr-" + globalGalacticClusterAdminSuffix,
-			Namespace: DefaultNamespace,
-			Labels:    map[string]string{"k3s.creator": appName},
+			Name:   "cr-" + suffix,
+			Labels: map[string]string{creatorLabel: appName},
 		},
 		Rules: []rbacv1.PolicyRule{
 			{
@@ -406,9 +587,8 @@ func createDefaultRBACForSA(ctx context.Context, c *K3dCluster) (string, error)
 
 	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "crb-" + globalGalacticClusterAdminSuffix,
-			Namespace: DefaultNamespace,
-			Labels:    map[string]string{"k3s.creator": appName},
+			Name:   "crb-" + suffix,
+			Labels: map[string]string{creatorLabel: appName},
 		},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: rbacv1.GroupName,
@@ -432,31 +612,22 @@ func createDefaultRBACForSA(ctx context.Context, c *K3dCluster) (string, error)
 	return sa.Name, nil
 }
 
-func (c *K3dCluster) waitForDefaultSACreation(ctx context.Context) error {
-	errRetriable := true
-
+func waitForDefaultSACreation(ctx context.Context, clientset kubernetes.Interface) error {
 	err := retry.OnError(wait.Backoff{
 		Steps:    20,
 		Duration: 500 * time.Millisecond,
 		Factor:   1.0,
 		Jitter:   0.1,
 	}, func(err error) bool {
-		return errRetriable
+		return true
 	}, func() error {
-		clientset, err := c.ClientSet()
-		if err != nil {
-			errRetriable = false
-			return err
-		}
-
-		_, err = clientset.CoreV1().ServiceAccounts(DefaultNamespace).Get(ctx, "default", metav1.GetOptions{})
+		_, err := clientset.CoreV1().ServiceAccounts(DefaultNamespace).Get(ctx, "default", metav1.GetOptions{})
 		if err != nil {
 			l.Log().Debug("testcluster-go: no default SA found")
 			return err
 		}
 
 		l.Log().Debug("testcluster-go: found default SA")
-		errRetriable = true
 		return nil
 	})
 
@@ -466,21 +637,13 @@ func (c *K3dCluster) waitForDefaultSACreation(ctx context.Context) error {
 	return nil
 }
 
-// panicAtStartError tries to remove the cluster and panics.
-// Testclusters-go is then responsible for resolving the panic.
-// The cluster may be nil.
-func panicAtStartError(ctx context.Context, cluster *K3dCluster, err error) error {
-	if cluster == nil {
-		panic(err.Error())
-		return err
-	}
-
+// terminateAtStartError tries to remove the cluster and returns the original error.
+func terminateAtStartError(ctx context.Context, cluster *K3dCluster, err error) error {
 	err2 := cluster.Terminate(ctx)
 	if err2 != nil {
 		l.Log().Errorf("Another error '%s' occurred while terminating the cluster due to the original error (you may want to clean-up the container landscape): %s", err2.Error(), err)
 	}
 
-	panic(err.Error())
 	return err
 }
 
@@ -499,25 +662,83 @@ func (c *K3dCluster) ClientSet() (kubernetes.Interface, error) {
 	return c.clientSet, nil
 }
 
+// StartupReport returns how long the phases of the cluster creation took. The
+// report of a reused cluster which was created by a previous test run is empty.
+func (c *K3dCluster) StartupReport() StartupReport {
+	return c.startupReport
+}
+
+// RestConfig returns the client configuration to build further K8s clients, f. i. dynamic clients.
+func (c *K3dCluster) RestConfig() (*rest.Config, error) {
+	if c.clientConfig == nil {
+		return nil, fmt.Errorf("cluster %s has no client configuration", c.ClusterName)
+	}
+	return c.clientConfig, nil
+}
+
+// KubeConfig returns the kube config which grants admin access to the cluster.
+// See WriteKubeConfig to write it to a file.
+func (c *K3dCluster) KubeConfig() (*api.Config, error) {
+	if c.kubeConfig == nil {
+		return nil, fmt.Errorf("cluster %s has no kube config", c.ClusterName)
+	}
+	return c.kubeConfig, nil
+}
+
 // NodeHealthCheckOpts customizes the way whether and how node health checks are executed.
 type NodeHealthCheckOpts struct {
 	// SkipCheck controls whether a node check should be executed (which is usually a good idea). Defaults to false
 	SkipCheck bool
+	// ExpectedNodes sets the number of nodes which must have joined the cluster. Defaults to 0 which accepts any
+	// number of nodes.
+	ExpectedNodes int
+	// Timeout sets how long the check waits for all nodes to become healthy. Defaults to 2 minutes.
+	Timeout time.Duration
 }
 
+const defaultNodeHealthCheckTimeout = 2 * time.Minute
+
 func (c *K3dCluster) checkNodeHealth(ctx context.Context, opts NodeHealthCheckOpts) error {
+	return checkNodeHealth(ctx, c.clientSet, opts)
+}
+
+func checkNodeHealth(ctx context.Context, clientSet kubernetes.Interface, opts NodeHealthCheckOpts) error {
 	if opts.SkipCheck {
 		l.Log().Debugf("testcluster-go: skipping health-check all nodes")
 		return nil
 	}
 
-	nodeInfo, err := health.FetchNodeInfo(ctx, c.clientSet)
-	if err != nil {
-		return err
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultNodeHealthCheckTimeout
 	}
 
-	err = health.CheckCondition(nodeInfo)
+	var lastErr error
+	var nodeInfo *health.Node
+	err := wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		nodeInfo, lastErr = health.FetchNodeInfo(ctx, clientSet)
+		if lastErr != nil {
+			return false, nil
+		}
+
+		lastErr = health.CheckNodeCount(nodeInfo, opts.ExpectedNodes)
+		if lastErr != nil {
+			l.Log().Debugf("testcluster-go: waiting for nodes: %s", lastErr.Error())
+			return false, nil
+		}
+
+		lastErr = health.CheckCondition(nodeInfo)
+		if lastErr != nil {
+			l.Log().Debugf("testcluster-go: waiting for healthy nodes: %s", lastErr.Error())
+			return false, nil
+		}
+
+		return true, nil
+	})
 	if err != nil {
+		if lastErr != nil {
+			return lastErr
+		}
 		return err
 	}
 
@@ -528,7 +749,7 @@ func (c *K3dCluster) checkNodeHealth(ctx context.Context, opts NodeHealthCheckOp
 // WriteKubeConfig writes a Kube Config into the directory. This directory is the
 // same where other Kube Configs may reside. This is useful when a testcluster
 // should be debugged manually.
-func (c *K3dCluster) WriteKubeConfig(ctx context.Context, t *testing.T) string {
+func (c *K3dCluster) WriteKubeConfig(ctx context.Context, t testing.TB) string {
 	t.Helper()
 	dir := t.TempDir()
 
@@ -557,7 +778,7 @@ func (c *K3dCluster) CtlKube(fieldManager string) (*YamlApplier, error) {
 
 // MustLookout creates a new Lookout that interacts with the current cluster. It
 // does not return an error but panics with the found error instead.
-func (c *K3dCluster) MustLookout(t *testing.T) *Lookout {
+func (c *K3dCluster) MustLookout(t testing.TB) *Lookout {
 	clientSet, err := c.ClientSet()
 	if err != nil {
 		errMsg := fmt.Sprintf("mustLookout could not build clientSet for cluster: %s", err.Error())
@@ -571,7 +792,7 @@ func (c *K3dCluster) MustLookout(t *testing.T) *Lookout {
 }
 
 // Lookout creates a new Lookout that interacts with the current cluster.
-func (c *K3dCluster) Lookout(t *testing.T) (*Lookout, error) {
+func (c *K3dCluster) Lookout(t testing.TB) (*Lookout, error) {
 	clientSet, err := c.ClientSet()
 	if err != nil {
 		return nil, fmt.Errorf("lookout could not build clientSet for cluster: %w", err)
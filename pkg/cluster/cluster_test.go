@@ -3,6 +3,40 @@ package cluster
 import (
 	"context"
 	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testCtx = context.Background()
+
+func Test_validateClusterNamePrefix(t *testing.T) {
+	t.Run("should generate prefix for empty prefix", func(t *testing.T) {
+		actual, err := validateClusterNamePrefix("")
+
+		require.NoError(t, err)
+		assert.Regexp(t, "^tc-[a-z0-9]{6}$", actual)
+	})
+	t.Run("should prepend default prefix", func(t *testing.T) {
+		actual, err := validateClusterNamePrefix("hello-world")
+
+		require.NoError(t, err)
+		assert.Equal(t, "tc-hello-world", actual)
+	})
+	t.Run("should fail on invalid prefix", func(t *testing.T) {
+		_, err := validateClusterNamePrefix("hello world")
+
+		require.Error(t, err)
+	})
+	t.Run("should fail on non-DNS prefix", func(t *testing.T) {
+		_, err := validateClusterNamePrefix("Hello_World")
+
+		require.Error(t, err)
+	})
+	t.Run("should fail on too long prefix", func(t *testing.T) {
+		_, err := validateClusterNamePrefix("a-very-long-prefix-that-exceeds-the-limit")
+
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,239 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	runtimeTypes "github.com/k3d-io/k3d/v5/pkg/runtimes/types"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// ArtifactsDirEnvVar sets the directory where diagnostics of failed tests are
+// collected. Each failed test gets its own sub-directory.
+// Defaults to the directory testclusters-go-artifacts in the temporary directory.
+const ArtifactsDirEnvVar = "TESTCLUSTERS_ARTIFACTS_DIR"
+
+const (
+	defaultArtifactsDir = "testclusters-go-artifacts"
+	diagnosticsTimeout  = 2 * time.Minute
+	// maxPodLogBytes keeps the artifacts of chatty pods small.
+	maxPodLogBytes   int64 = 10 << 20
+	artifactFileMode       = 0644
+	artifactDirMode        = 0755
+)
+
+var (
+	artifactDirsMutex sync.Mutex
+	// artifactDirs maps test names to their artifacts directory so that all dumps
+	// of a test end up in the same directory.
+	artifactDirs = map[string]string{}
+)
+
+// serverLogWriter is implemented by clusters which can provide the logs of
+// their K8s server nodes.
+type serverLogWriter interface {
+	writeServerLogs(ctx context.Context, dir string) error
+}
+
+// dumpDiagnosticsOnFailure collects diagnostics of the cluster if the test
+// failed so that the failure can be investigated after the cluster is gone. An
+// empty namespace collects the resources of all namespaces.
+func dumpDiagnosticsOnFailure(t testing.TB, cluster Cluster, namespace string) {
+	if !t.Failed() {
+		return
+	}
+
+	clientSet, err := cluster.ClientSet()
+	if err != nil {
+		t.Logf("testcluster-go: Cannot collect diagnostics without clientset: %s", err.Error())
+		return
+	}
+	if clientSet == nil {
+		return
+	}
+
+	dir, err := artifactsDir(t.Name())
+	if err == nil && namespace != "" {
+		dir = filepath.Join(dir, "namespaces", namespace)
+		err = os.MkdirAll(dir, artifactDirMode)
+	}
+	if err != nil {
+		t.Logf("testcluster-go: Cannot collect diagnostics: %s", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	errs := []error{collectDiagnostics(ctx, clientSet, dir, namespace)}
+	if logWriter, ok := cluster.(serverLogWriter); ok && namespace == "" {
+		errs = append(errs, logWriter.writeServerLogs(ctx, filepath.Join(dir, "nodes")))
+	}
+	if err := errors.Join(errs...); err != nil {
+		l.Log().Warnf("testcluster-go: Some diagnostics could not be collected: %s", err.Error())
+	}
+
+	t.Logf("testcluster-go: Collected cluster diagnostics of the failed test in %s", dir)
+}
+
+// artifactsDir creates the directory for the diagnostics of the given test.
+func artifactsDir(testName string) (string, error) {
+	artifactDirsMutex.Lock()
+	defer artifactDirsMutex.Unlock()
+
+	if dir, ok := artifactDirs[testName]; ok {
+		return dir, nil
+	}
+
+	dir := filepath.Join(artifactsBaseDir(), fmt.Sprintf("%s-%s", namespacePrefix(testName), time.Now().Format("20060102-150405.000")))
+	err := os.MkdirAll(dir, artifactDirMode)
+	if err != nil {
+		return "", fmt.Errorf("failed to create artifacts directory %s: %w", dir, err)
+	}
+	artifactDirs[testName] = dir
+
+	return dir, nil
+}
+
+func artifactsBaseDir() string {
+	if base := os.Getenv(ArtifactsDirEnvVar); base != "" {
+		return base
+	}
+	return filepath.Join(os.TempDir(), defaultArtifactsDir)
+}
+
+// collectDiagnostics writes nodes, pods, events and pod logs into the given
+// directory. It continues on errors so that as much as possible is collected.
+func collectDiagnostics(ctx context.Context, clientSet kubernetes.Interface, dir, namespace string) error {
+	var errs []error
+
+	nodes, err := clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	errs = append(errs, writeArtifact(dir, "nodes.yaml", nodes, err))
+
+	events, err := clientSet.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	errs = append(errs, writeArtifact(dir, "events.yaml", events, err))
+
+	pods, err := clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	errs = append(errs, writeArtifact(dir, "pods.yaml", pods, err))
+	if err != nil {
+		return errors.Join(errs...)
+	}
+
+	for _, pod := range pods.Items {
+		errs = append(errs, writePodLogs(ctx, clientSet, filepath.Join(dir, "logs", pod.Namespace, pod.Name), pod))
+	}
+
+	return errors.Join(errs...)
+}
+
+func writeArtifact(dir, name string, obj interface{}, listErr error) error {
+	if listErr != nil {
+		return fmt.Errorf("failed to list %s: %w", name, listErr)
+	}
+
+	content, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, name), content, artifactFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// writePodLogs writes the logs of all containers of the pod. The logs of the
+// previous container instance are written as well if the container restarted.
+func writePodLogs(ctx context.Context, clientSet kubernetes.Interface, dir string, pod v1.Pod) error {
+	err := os.MkdirAll(dir, artifactDirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create log directory %s: %w", dir, err)
+	}
+
+	var errs []error
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		errs = append(errs, writeContainerLogs(ctx, clientSet, pod, status.Name, false, filepath.Join(dir, status.Name+".log")))
+		if status.RestartCount > 0 {
+			errs = append(errs, writeContainerLogs(ctx, clientSet, pod, status.Name, true, filepath.Join(dir, status.Name+".previous.log")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func writeContainerLogs(ctx context.Context, clientSet kubernetes.Interface, pod v1.Pod, container string, previous bool, path string) error {
+	limitBytes := maxPodLogBytes
+	logOpts := &v1.PodLogOptions{Container: container, Previous: previous, LimitBytes: &limitBytes}
+
+	logs, err := clientSet.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOpts).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get logs of container %s in pod %s/%s: %w", container, pod.Namespace, pod.Name, err)
+	}
+	defer logs.Close()
+
+	return writeStream(path, logs, io.Copy)
+}
+
+// writeServerLogs writes the logs of the k3s server and agent nodes.
+func (c *K3dCluster) writeServerLogs(ctx context.Context, dir string) error {
+	if c.clusterConfig == nil || c.containerRuntime == nil {
+		return nil
+	}
+
+	err := os.MkdirAll(dir, artifactDirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create node log directory %s: %w", dir, err)
+	}
+
+	var errs []error
+	for _, node := range c.clusterConfig.Cluster.Nodes {
+		if node.Role != k3dTypes.ServerRole && node.Role != k3dTypes.AgentRole {
+			continue
+		}
+
+		logs, err := c.containerRuntime.GetNodeLogs(ctx, node, time.Time{}, &runtimeTypes.NodeLogsOpts{})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get logs of node %s: %w", node.Name, err))
+			continue
+		}
+
+		// container logs of the runtime multiplex stdout and stderr
+		errs = append(errs, writeStream(filepath.Join(dir, node.Name+".log"), logs, func(dst io.Writer, src io.Reader) (int64, error) {
+			return stdcopy.StdCopy(dst, dst, src)
+		}))
+		_ = logs.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+func writeStream(path string, src io.Reader, copyFn func(dst io.Writer, src io.Reader) (int64, error)) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	_, err = copyFn(file, src)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// failedT reports the wrapped test as failed without failing it.
+type failedT struct {
+	testing.TB
+}
+
+func (f failedT) Failed() bool {
+	return true
+}
+
+func diagnosticsTestObjects() []v1.Pod {
+	return []v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "ns-a"},
+			Status: v1.PodStatus{
+				InitContainerStatuses: []v1.ContainerStatus{{Name: "init"}},
+				ContainerStatuses:     []v1.ContainerStatus{{Name: "nginx", RestartCount: 2}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "redis", Namespace: "ns-b"},
+			Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{Name: "redis"}}},
+		},
+	}
+}
+
+func Test_collectDiagnostics(t *testing.T) {
+	pods := diagnosticsTestObjects()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "k3d-server-0"}}
+	event := &v1.Event{ObjectMeta: metav1.ObjectMeta{Name: "nginx.123", Namespace: "ns-a"}, Reason: "BackOff"}
+	cl := NewFakeCluster(t, &pods[0], &pods[1], node, event)
+
+	t.Run("should collect all namespaces", func(t *testing.T) {
+		dir := t.TempDir()
+
+		err := collectDiagnostics(testCtx, cl.FakeClientSet(), dir, "")
+
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "nodes.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "pods.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "events.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "logs", "ns-a", "nginx", "init.log"))
+		assert.FileExists(t, filepath.Join(dir, "logs", "ns-a", "nginx", "nginx.log"))
+		assert.FileExists(t, filepath.Join(dir, "logs", "ns-a", "nginx", "nginx.previous.log"))
+		assert.FileExists(t, filepath.Join(dir, "logs", "ns-b", "redis", "redis.log"))
+		assert.NoFileExists(t, filepath.Join(dir, "logs", "ns-b", "redis", "redis.previous.log"))
+
+		events, err := os.ReadFile(filepath.Join(dir, "events.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(events), "BackOff")
+	})
+	t.Run("should only collect the given namespace", func(t *testing.T) {
+		dir := t.TempDir()
+
+		err := collectDiagnostics(testCtx, cl.FakeClientSet(), dir, "ns-b")
+
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "logs", "ns-b", "redis", "redis.log"))
+		assert.NoDirExists(t, filepath.Join(dir, "logs", "ns-a"))
+	})
+}
+
+func Test_dumpDiagnosticsOnFailure(t *testing.T) {
+	pods := diagnosticsTestObjects()
+
+	t.Run("should not dump passed tests", func(t *testing.T) {
+		base := t.TempDir()
+		t.Setenv(ArtifactsDirEnvVar, base)
+
+		dumpDiagnosticsOnFailure(t, NewFakeCluster(t, &pods[0]), "")
+
+		entries, err := os.ReadDir(base)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+	t.Run("should dump failed tests into one directory per test", func(t *testing.T) {
+		base := t.TempDir()
+		t.Setenv(ArtifactsDirEnvVar, base)
+		cl := NewFakeCluster(t, &pods[0], &pods[1])
+
+		dumpDiagnosticsOnFailure(failedT{t}, cl, "ns-b")
+		dumpDiagnosticsOnFailure(failedT{t}, cl, "")
+
+		entries, err := os.ReadDir(base)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		dir := filepath.Join(base, entries[0].Name())
+		assert.Contains(t, entries[0].Name(), "test-dumpdiagnosticsonfailure-should")
+		assert.FileExists(t, filepath.Join(dir, "pods.yaml"))
+		assert.FileExists(t, filepath.Join(dir, "namespaces", "ns-b", "logs", "ns-b", "redis", "redis.log"))
+	})
+}
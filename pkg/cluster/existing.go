@@ -0,0 +1,202 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/test-clusters/testclusters-go/pkg/naming"
+)
+
+// BackendExisting uses a pre-provisioned cluster from a kube config instead of
+// creating a new cluster, see Opts.KubeConfigPath and Opts.KubeContext.
+const BackendExisting Backend = "existing"
+
+func init() {
+	RegisterBackend(BackendExisting, func(ctx context.Context, opts Opts) (Cluster, error) {
+		cluster, err := CreateExistingCluster(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return cluster, nil
+	})
+}
+
+var _ Cluster = &ExistingCluster{}
+
+// ExistingCluster abstracts a pre-provisioned cluster, f. i. on CI runners which
+// cannot run a container runtime. Terminating an ExistingCluster only deletes
+// the resources testclusters-go created, the cluster itself remains.
+type ExistingCluster struct {
+	kubeConfig   *api.Config
+	clientConfig *rest.Config
+	clientSet    kubernetes.Interface
+	// ContextName contains the kube config context which is used to access the cluster.
+	ContextName string
+	// AdminServiceAccount contains the name of a service account with cluster admin
+	// permissions which was created for the test.
+	AdminServiceAccount string
+	rbacSuffix          string
+}
+
+// CreateExistingCluster connects to the cluster of the kube config selected by
+// Opts.KubeConfigPath and Opts.KubeContext. Like CreateK3dCluster, it creates an
+// admin service account and waits until the cluster nodes are healthy. The
+// caller is responsible to Terminate the cluster handle.
+func CreateExistingCluster(ctx context.Context, opts Opts) (*ExistingCluster, error) {
+	kubeConfig, contextName, err := loadExistingKubeConfig(opts.KubeConfigPath, opts.KubeContext)
+	if err != nil {
+		return nil, err
+	}
+	l.Log().Infof("testcluster-go: Using existing cluster of context %s", contextName)
+
+	clientConfig, err := clientcmd.NewDefaultClientConfig(*kubeConfig, nil).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client config for context %s: %w", contextName, err)
+	}
+
+	clientSet, err := kubernetes.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	return setupExistingCluster(ctx, &ExistingCluster{
+		kubeConfig:   kubeConfig,
+		clientConfig: clientConfig,
+		clientSet:    clientSet,
+		ContextName:  contextName,
+	})
+}
+
+func setupExistingCluster(ctx context.Context, cl *ExistingCluster) (*ExistingCluster, error) {
+	// other tests may use the same cluster at the same time
+	cl.rbacSuffix = naming.MustGenerateK8sName(globalGalacticClusterAdminSuffix)
+
+	sa, err := createDefaultRBACForSA(ctx, cl.clientSet, cl.rbacSuffix)
+	if err != nil {
+		return nil, terminateExistingAtStartError(ctx, cl, fmt.Errorf("failed to create default RBAC for SA: %w", err))
+	}
+	cl.AdminServiceAccount = sa
+
+	err = waitForDefaultSACreation(ctx, cl.clientSet)
+	if err != nil {
+		return nil, terminateExistingAtStartError(ctx, cl, fmt.Errorf("failed to wait for default service account: %w", err))
+	}
+
+	err = checkNodeHealth(ctx, cl.clientSet, NodeHealthCheckOpts{})
+	if err != nil {
+		return nil, terminateExistingAtStartError(ctx, cl, fmt.Errorf("failed to check node health: %w", err))
+	}
+
+	return cl, nil
+}
+
+// loadExistingKubeConfig loads the kube config like kubectl does, f. i. from the
+// KUBECONFIG environment variable, and selects the given context.
+func loadExistingKubeConfig(path, kubeContext string) (*api.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = path
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).RawConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kube config: %w", err)
+	}
+
+	if kubeContext == "" {
+		kubeContext = rawConfig.CurrentContext
+	}
+	if _, ok := rawConfig.Contexts[kubeContext]; !ok {
+		return nil, "", fmt.Errorf("context '%s' not found in kube config", kubeContext)
+	}
+	rawConfig.CurrentContext = kubeContext
+
+	return &rawConfig, kubeContext, nil
+}
+
+// terminateExistingAtStartError tries to remove the created resources and returns the original error.
+func terminateExistingAtStartError(ctx context.Context, cluster *ExistingCluster, err error) error {
+	err2 := cluster.Terminate(ctx)
+	if err2 != nil {
+		l.Log().Errorf("Another error '%s' occurred while cleaning up the existing cluster due to the original error: %s", err2.Error(), err)
+	}
+
+	return err
+}
+
+// Terminate deletes the resources testclusters-go created in the cluster. The
+// cluster itself remains.
+func (c *ExistingCluster) Terminate(ctx context.Context) error {
+	return deleteDefaultRBACForSA(ctx, c.clientSet, c.rbacSuffix)
+}
+
+// ClientSet returns a K8s clientset which allows to interoperate with the cluster K8s API.
+func (c *ExistingCluster) ClientSet() (kubernetes.Interface, error) {
+	return c.clientSet, nil
+}
+
+// RestConfig returns the client configuration to build further K8s clients, f. i. dynamic clients.
+func (c *ExistingCluster) RestConfig() (*rest.Config, error) {
+	if c.clientConfig == nil {
+		return nil, fmt.Errorf("existing cluster of context %s has no client configuration", c.ContextName)
+	}
+	return c.clientConfig, nil
+}
+
+// KubeConfig returns the kube config with the used context as current context.
+func (c *ExistingCluster) KubeConfig() (*api.Config, error) {
+	if c.kubeConfig == nil {
+		return nil, fmt.Errorf("existing cluster of context %s has no kube config", c.ContextName)
+	}
+	return c.kubeConfig, nil
+}
+
+// CtlKube returns a YamlApplier which applies resources into the default namespace.
+func (c *ExistingCluster) CtlKube(fieldManager string) (*YamlApplier, error) {
+	yamlApplier, err := NewYamlApplier(c.clientConfig, fieldManager, DefaultNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("ctlkube call failed: %w", err)
+	}
+	return yamlApplier, nil
+}
+
+// Lookout creates a new Lookout that interacts with the cluster.
+func (c *ExistingCluster) Lookout(t testing.TB) (*Lookout, error) {
+	return &Lookout{
+		t: t,
+		c: c.clientSet,
+	}, nil
+}
+
+// Namespace creates a new namespace with a unique name for the given test. The
+// namespace will be deleted once the test finishes.
+func (c *ExistingCluster) Namespace(t testing.TB) *Namespace {
+	t.Helper()
+	return newNamespace(t, c, restConfigApplierFactory(c.clientConfig))
+}
+
+// deleteDefaultRBACForSA deletes the resources of createDefaultRBACForSA.
+// Resources which do not exist are ignored.
+func deleteDefaultRBACForSA(ctx context.Context, clientSet kubernetes.Interface, suffix string) error {
+	ignoreNotFound := func(err error) error {
+		if k8sErrs.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return errors.Join(
+		ignoreNotFound(clientSet.RbacV1().ClusterRoleBindings().Delete(ctx, "crb-"+suffix, metav1.DeleteOptions{})),
+		ignoreNotFound(clientSet.RbacV1().ClusterRoles().Delete(ctx, "cr-"+suffix, metav1.DeleteOptions{})),
+		ignoreNotFound(clientSet.CoreV1().ServiceAccounts(DefaultNamespace).Delete(ctx, "sa-"+suffix, metav1.DeleteOptions{})),
+	)
+}
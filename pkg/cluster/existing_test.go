@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_loadExistingKubeConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeContext string
+		wantContext string
+		wantErr     string
+	}{
+		{"uses current context", "", "ci", ""},
+		{"selects context", "staging", "staging", ""},
+		{"fails on unknown context", "production", "", "context 'production' not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeConfig, contextName, err := loadExistingKubeConfig("testdata/kubeconfig.yaml", tt.kubeContext)
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantContext, contextName)
+			assert.Equal(t, tt.wantContext, kubeConfig.CurrentContext)
+		})
+	}
+}
+
+func Test_setupExistingCluster(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: DefaultNamespace}},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+			Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}},
+		},
+	)
+
+	cl, err := setupExistingCluster(testCtx, &ExistingCluster{clientSet: clientSet, ContextName: "ci"})
+
+	require.NoError(t, err)
+	assert.Regexp(t, "^sa-ford-prefect-[a-f0-9]{8}$", cl.AdminServiceAccount)
+	_, err = clientSet.RbacV1().ClusterRoleBindings().Get(testCtx, "crb-"+cl.rbacSuffix, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	t.Run("terminate should only delete created resources", func(t *testing.T) {
+		err := cl.Terminate(testCtx)
+
+		require.NoError(t, err)
+		_, err = clientSet.CoreV1().ServiceAccounts(DefaultNamespace).Get(testCtx, cl.AdminServiceAccount, metav1.GetOptions{})
+		assert.True(t, k8sErrs.IsNotFound(err))
+		_, err = clientSet.RbacV1().ClusterRoles().Get(testCtx, "cr-"+cl.rbacSuffix, metav1.GetOptions{})
+		assert.True(t, k8sErrs.IsNotFound(err))
+		_, err = clientSet.CoreV1().ServiceAccounts(DefaultNamespace).Get(testCtx, "default", metav1.GetOptions{})
+		assert.NoError(t, err)
+		_, err = clientSet.CoreV1().Nodes().Get(testCtx, "node-0", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+	t.Run("terminate should ignore deleted resources", func(t *testing.T) {
+		err := cl.Terminate(testCtx)
+
+		require.NoError(t, err)
+	})
+}
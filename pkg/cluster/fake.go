@@ -0,0 +1,238 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudogu/k8s-apply-lib/apply"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clientScheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	k8sTesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// BackendFake provides an empty in-memory FakeCluster, see also NewFakeCluster.
+const BackendFake Backend = "fake"
+
+func init() {
+	RegisterBackend(BackendFake, func(ctx context.Context, opts Opts) (Cluster, error) {
+		return newFakeCluster(), nil
+	})
+}
+
+var _ Cluster = &FakeCluster{}
+
+// FakeCluster is an in-memory cluster without nodes or controllers which is
+// backed by client-go's fake clients. It allows unit tests to use Lookout,
+// YamlApplier and Namespace without a container runtime. The typed clientset,
+// the dynamic client and the YamlApplier share the same objects.
+//
+// Since there are no controllers, objects must be created in their desired state,
+// f. i. pods with their status.
+type FakeCluster struct {
+	clientSet     *fake.Clientset
+	dynamicClient *dynamicFake.FakeDynamicClient
+	scheme        *runtime.Scheme
+	restMapper    meta.RESTMapper
+}
+
+// NewFakeCluster creates a new in-memory cluster which contains the given
+// objects.
+func NewFakeCluster(t testing.TB, objects ...runtime.Object) *FakeCluster {
+	t.Helper()
+
+	return newFakeCluster(objects...)
+}
+
+func newFakeCluster(objects ...runtime.Object) *FakeCluster {
+	scheme := clientScheme.Scheme
+	clientSet := fake.NewSimpleClientset(objects...)
+
+	// the dynamic client uses the tracker of the clientset so that both see the same objects
+	dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(scheme, nil)
+	dynamicClient.ReactionChain = nil
+	dynamicClient.WatchReactionChain = nil
+	dynamicClient.AddReactor("*", "*", typedObjectReaction(clientSet.Tracker(), scheme))
+	dynamicClient.AddWatchReactor("*", func(action k8sTesting.Action) (bool, watch.Interface, error) {
+		w, err := clientSet.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		return true, w, err
+	})
+
+	return &FakeCluster{
+		clientSet:     clientSet,
+		dynamicClient: dynamicClient,
+		scheme:        scheme,
+		restMapper:    testrestmapper.TestOnlyStaticRESTMapper(scheme),
+	}
+}
+
+// typedObjectReaction stores objects of known kinds in their typed form so that
+// the typed clientset can read objects which were written by the dynamic client.
+func typedObjectReaction(tracker k8sTesting.ObjectTracker, scheme *runtime.Scheme) k8sTesting.ReactionFunc {
+	objectReaction := k8sTesting.ObjectReaction(tracker)
+
+	return func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		switch a := action.(type) {
+		case k8sTesting.CreateActionImpl:
+			obj, err := toTypedObject(scheme, a.Object)
+			if err != nil {
+				return true, nil, err
+			}
+			a.Object = obj
+			return objectReaction(a)
+		case k8sTesting.UpdateActionImpl:
+			obj, err := toTypedObject(scheme, a.Object)
+			if err != nil {
+				return true, nil, err
+			}
+			a.Object = obj
+			return objectReaction(a)
+		default:
+			return objectReaction(action)
+		}
+	}
+}
+
+// toTypedObject converts unstructured objects into their typed form if the kind
+// is known to the scheme. Other objects are returned as they are.
+func toTypedObject(scheme *runtime.Scheme, obj runtime.Object) (runtime.Object, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+
+	typed, err := scheme.New(u.GroupVersionKind())
+	if err != nil {
+		// f. i. custom resources
+		return obj, nil
+	}
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+	return typed, nil
+}
+
+// ClientSet returns the fake clientset of the cluster.
+func (c *FakeCluster) ClientSet() (kubernetes.Interface, error) {
+	return c.clientSet, nil
+}
+
+// FakeClientSet returns the fake clientset of the cluster, f. i. to add reactors
+// which simulate API errors.
+func (c *FakeCluster) FakeClientSet() *fake.Clientset {
+	return c.clientSet
+}
+
+// DynamicClient returns a fake dynamic client which shares its objects with the
+// clientset.
+func (c *FakeCluster) DynamicClient() dynamic.Interface {
+	return c.dynamicClient
+}
+
+// RestConfig returns an error because there is no API server to connect to.
+func (c *FakeCluster) RestConfig() (*rest.Config, error) {
+	return nil, fmt.Errorf("fake clusters have no API server (use ClientSet or DynamicClient instead)")
+}
+
+// KubeConfig returns an error because there is no API server to connect to.
+func (c *FakeCluster) KubeConfig() (*api.Config, error) {
+	return nil, fmt.Errorf("fake clusters have no API server (use ClientSet or DynamicClient instead)")
+}
+
+// CtlKube returns a YamlApplier which decodes resources into the fake cluster.
+func (c *FakeCluster) CtlKube(fieldManager string) (*YamlApplier, error) {
+	return c.yamlApplier(fieldManager, DefaultNamespace)
+}
+
+func (c *FakeCluster) yamlApplier(_, namespace string) (*YamlApplier, error) {
+	return &YamlApplier{
+		applier:          &fakeApplier{cluster: c},
+		deleter:          &dynamicDeleter{client: c.dynamicClient, resourceOf: c.resourceOf, pollInterval: time.Millisecond},
+		defaultNamespace: namespace,
+	}, nil
+}
+
+// Lookout creates a new Lookout that interacts with the fake cluster.
+func (c *FakeCluster) Lookout(t testing.TB) (*Lookout, error) {
+	return &Lookout{
+		t: t,
+		c: c.clientSet,
+	}, nil
+}
+
+// Namespace creates a new namespace with a unique name for the given test. The
+// namespace will be deleted once the test finishes.
+func (c *FakeCluster) Namespace(t testing.TB) *Namespace {
+	t.Helper()
+	return newNamespace(t, c, c.yamlApplier)
+}
+
+// Terminate does nothing because the fake cluster only lives in memory.
+func (c *FakeCluster) Terminate(context.Context) error {
+	return nil
+}
+
+// fakeApplier applies YAML resources like the k8s-apply-lib applier but writes
+// them directly into the fake cluster.
+type fakeApplier struct {
+	cluster *FakeCluster
+}
+
+func (a *fakeApplier) Apply(yamlResource apply.YamlDocument, namespace string) error {
+	obj := &unstructured.Unstructured{}
+	_, gvk, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(yamlResource, nil, obj)
+	if err != nil {
+		return fmt.Errorf("could not decode YAML document '%s': %w", string(yamlResource), err)
+	}
+
+	gvr, namespaced, _ := a.cluster.resourceOf(*gvk)
+	if namespaced {
+		obj.SetNamespace(namespace)
+	}
+
+	typed, err := toTypedObject(a.cluster.scheme, obj)
+	if err != nil {
+		return err
+	}
+
+	tracker := a.cluster.clientSet.Tracker()
+	_, err = tracker.Get(gvr, obj.GetNamespace(), obj.GetName())
+	if k8sErrs.IsNotFound(err) {
+		err = tracker.Create(gvr, typed, obj.GetNamespace())
+	} else if err == nil {
+		err = tracker.Update(gvr, typed, obj.GetNamespace())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// resourceOf maps the kind to its resource. Unknown kinds (f. i. custom
+// resources) are guessed to be namespaced.
+func (c *FakeCluster) resourceOf(gvk schema.GroupVersionKind) (schema.GroupVersionResource, bool, error) {
+	gvr, namespaced, err := restResourceMapper(c.restMapper)(gvk)
+	if err != nil {
+		gvr, _ = meta.UnsafeGuessKindToResource(gvk)
+		return gvr, true, nil
+	}
+
+	return gvr, namespaced, nil
+}
@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var configMapResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+const configMapYaml = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+data:
+  key: %s
+`
+
+func TestNewFakeCluster(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: DefaultNamespace, Labels: map[string]string{"app": "nginx"}}}
+	cl := NewFakeCluster(t, pod)
+
+	t.Run("lookout should find initial objects", func(t *testing.T) {
+		lookout, err := cl.Lookout(t)
+		require.NoError(t, err)
+
+		actual, err := lookout.Pod(DefaultNamespace, "nginx").Raw(testCtx)
+
+		require.NoError(t, err)
+		assert.Equal(t, pod.Labels, actual.Labels)
+		assert.NoError(t, lookout.Pods(DefaultNamespace).ByLabels("app=nginx").List().Len(testCtx, 1))
+	})
+	t.Run("dynamic client should find initial objects", func(t *testing.T) {
+		actual, err := cl.DynamicClient().Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).
+			Namespace(DefaultNamespace).Get(testCtx, "nginx", metav1.GetOptions{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "nginx", actual.GetName())
+	})
+	t.Run("should have no API server", func(t *testing.T) {
+		_, err := cl.RestConfig()
+		assert.Error(t, err)
+		_, err = cl.KubeConfig()
+		assert.Error(t, err)
+	})
+}
+
+func TestFakeCluster_CtlKube(t *testing.T) {
+	t.Run("should create and update namespaced resources", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		require.NoError(t, kubectl.ApplyWithFile(testCtx, []byte(fmt.Sprintf(configMapYaml, "first"))))
+		require.NoError(t, kubectl.ApplyWithFile(testCtx, []byte(fmt.Sprintf(configMapYaml, "second"))))
+
+		actual, err := cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "my-config", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "second", actual.Data["key"])
+	})
+	t.Run("should not set namespace of cluster-scoped resources", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyWithFile(testCtx, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: my-namespace\n"))
+
+		require.NoError(t, err)
+		_, err = cl.FakeClientSet().CoreV1().Namespaces().Get(testCtx, "my-namespace", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+	t.Run("should fail on invalid yaml", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyWithFile(testCtx, []byte("not: a resource"))
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "could not decode YAML document")
+	})
+}
+
+func TestFakeCluster_DynamicClient(t *testing.T) {
+	cl := NewFakeCluster(t)
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("my-config")
+
+	_, err := cl.DynamicClient().Resource(configMapResource).Namespace(DefaultNamespace).Create(testCtx, configMap, metav1.CreateOptions{})
+
+	require.NoError(t, err)
+	_, err = cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "my-config", metav1.GetOptions{})
+	assert.NoError(t, err)
+	list, err := cl.DynamicClient().Resource(configMapResource).Namespace(DefaultNamespace).List(testCtx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+}
+
+func TestFakeCluster_Namespace(t *testing.T) {
+	cl := NewFakeCluster(t)
+
+	var name string
+	t.Run("TestSomething", func(t *testing.T) {
+		ns := cl.Namespace(t)
+		name = ns.Name
+
+		require.NoError(t, ns.Kubectl.ApplyWithFile(testCtx, []byte(fmt.Sprintf(configMapYaml, "value"))))
+		_, err := cl.FakeClientSet().CoreV1().ConfigMaps(ns.Name).Get(testCtx, "my-config", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	_, err := cl.FakeClientSet().CoreV1().Namespaces().Get(testCtx, name, metav1.GetOptions{})
+	assert.True(t, k8sErrs.IsNotFound(err))
+}
+
+func TestCreateCluster_fake(t *testing.T) {
+	cl, err := CreateCluster(testCtx, Opts{Backend: BackendFake})
+
+	require.NoError(t, err)
+	assert.IsType(t, &FakeCluster{}, cl)
+}
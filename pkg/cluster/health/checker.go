@@ -36,3 +36,18 @@ func CheckCondition(nodeInfo *Node) error {
 
 	return nil
 }
+
+// CheckNodeCount returns an error if the number of nodes does not match the
+// expected number of nodes. An expected number of 0 accepts any number of nodes.
+func CheckNodeCount(nodeInfo *Node, expected int) error {
+	if expected == 0 {
+		return nil
+	}
+
+	actual := len(nodeInfo.Nodes)
+	if actual != expected {
+		return fmt.Errorf("unexpected number of nodes: expected: %d; actual: %d (%s)", expected, actual, nodeInfo.String())
+	}
+
+	return nil
+}
@@ -73,3 +73,27 @@ func TestCheckCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckNodeCount(t *testing.T) {
+	twoNodes := &Node{Nodes: []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "server-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "agent-0"}},
+	}}
+	tests := []struct {
+		name     string
+		expected int
+		wantErr  bool
+	}{
+		{name: "accepts any number of nodes", expected: 0, wantErr: false},
+		{name: "matches number of nodes", expected: 2, wantErr: false},
+		{name: "misses nodes", expected: 3, wantErr: true},
+		{name: "finds too many nodes", expected: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckNodeCount(twoNodes, tt.expected); (err != nil) != tt.wantErr {
+				t.Errorf("CheckNodeCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
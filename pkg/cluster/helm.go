@@ -0,0 +1,258 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// helmDriver stores release information in secrets like the helm CLI does.
+	helmDriver = "secret"
+	// helmTimeout limits how long Helm waits until the resources of a release are ready.
+	helmTimeout = 5 * time.Minute
+)
+
+// HelmClient installs Helm charts into a cluster with the Helm Go SDK, see
+// K3dCluster.Helm.
+type HelmClient struct {
+	t         testing.TB
+	cluster   Cluster
+	getter    *restClientGetter
+	namespace string
+	config    *action.Configuration
+
+	releasesMutex sync.Mutex
+	// releases contains the installed releases which will be uninstalled on test cleanup.
+	releases map[string]bool
+}
+
+// Helm returns a HelmClient which installs charts into the default namespace.
+// Releases which are still installed when the test finishes will be uninstalled.
+func (c *K3dCluster) Helm(t testing.TB) (*HelmClient, error) {
+	t.Helper()
+	return newHelmClient(t, c, c.clientConfig, c.kubeConfig, DefaultNamespace)
+}
+
+// Helm returns a HelmClient which installs charts into the default namespace.
+// Releases which are still installed when the test finishes will be uninstalled.
+func (c *ExistingCluster) Helm(t testing.TB) (*HelmClient, error) {
+	t.Helper()
+	return newHelmClient(t, c, c.clientConfig, c.kubeConfig, DefaultNamespace)
+}
+
+func newHelmClient(t testing.TB, cluster Cluster, restConfig *rest.Config, kubeConfig *api.Config, namespace string) (*HelmClient, error) {
+	if restConfig == nil || kubeConfig == nil {
+		return nil, fmt.Errorf("cannot create helm client for a cluster without kube config")
+	}
+
+	getter := &restClientGetter{restConfig: restConfig, kubeConfig: kubeConfig, namespace: namespace}
+	config := &action.Configuration{}
+	err := config.Init(getter, namespace, helmDriver, func(format string, v ...interface{}) {
+		l.Log().Debugf("testcluster-go: helm: "+format, v...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize helm for namespace %s: %w", namespace, err)
+	}
+
+	return newHelmClientWithConfig(t, cluster, getter, config, namespace), nil
+}
+
+func newHelmClientWithConfig(t testing.TB, cluster Cluster, getter *restClientGetter, config *action.Configuration, namespace string) *HelmClient {
+	h := &HelmClient{
+		t:         t,
+		cluster:   cluster,
+		getter:    getter,
+		namespace: namespace,
+		config:    config,
+		releases:  map[string]bool{},
+	}
+
+	t.Cleanup(func() {
+		if keepsOnFailure(t, cluster) {
+			return
+		}
+
+		err := h.uninstallAll()
+		if err != nil {
+			t.Errorf("testcluster-go: Unexpected error while uninstalling helm releases: %s", err.Error())
+		}
+	})
+
+	return h
+}
+
+// InNamespace returns a HelmClient which installs charts into the given
+// namespace, f. i. the namespace of Namespace. Its releases will be uninstalled
+// as well once the test finishes.
+func (h *HelmClient) InNamespace(namespace string) (*HelmClient, error) {
+	h.t.Helper()
+	return newHelmClient(h.t, h.cluster, h.getter.restConfig, h.getter.kubeConfig, namespace)
+}
+
+// Install installs the chart from a local chart directory or a packaged .tgz
+// chart with the given values. The namespace is created if it does not exist.
+// Install waits until the resources of the release are ready.
+func (h *HelmClient) Install(ctx context.Context, releaseName, chartPath string, values map[string]any) (*release.Release, error) {
+	chrt, err := loadChart(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(h.config)
+	install.ReleaseName = releaseName
+	install.Namespace = h.namespace
+	install.CreateNamespace = true
+	install.Wait = true
+	install.Timeout = helmTimeout
+
+	rel, err := install.RunWithContext(ctx, chrt, values)
+	if rel != nil {
+		// a failed installation may leave a release behind which must be uninstalled as well,
+		// but a release of the same name which existed before does not belong to this client
+		h.track(releaseName, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to install chart %s as release %s: %w", chartPath, releaseName, err)
+	}
+
+	return rel, nil
+}
+
+// Upgrade upgrades an installed release to the chart with the given values and
+// waits until the resources of the release are ready.
+func (h *HelmClient) Upgrade(ctx context.Context, releaseName, chartPath string, values map[string]any) (*release.Release, error) {
+	chrt, err := loadChart(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := action.NewUpgrade(h.config)
+	upgrade.Namespace = h.namespace
+	upgrade.Wait = true
+	upgrade.Timeout = helmTimeout
+
+	rel, err := upgrade.RunWithContext(ctx, releaseName, chrt, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade release %s to chart %s: %w", releaseName, chartPath, err)
+	}
+
+	return rel, nil
+}
+
+// Uninstall uninstalls the release and waits until its resources are deleted.
+// The context is not used yet because the Helm SDK does not support it for
+// uninstallations.
+func (h *HelmClient) Uninstall(_ context.Context, releaseName string) error {
+	return h.uninstall(releaseName, false)
+}
+
+func (h *HelmClient) uninstall(releaseName string, ignoreNotFound bool) error {
+	uninstall := action.NewUninstall(h.config)
+	uninstall.Wait = true
+	uninstall.Timeout = helmTimeout
+	uninstall.IgnoreNotFound = ignoreNotFound
+
+	_, err := uninstall.Run(releaseName)
+	if err != nil {
+		return fmt.Errorf("failed to uninstall release %s: %w", releaseName, err)
+	}
+	h.track(releaseName, false)
+
+	return nil
+}
+
+func (h *HelmClient) track(releaseName string, installed bool) {
+	h.releasesMutex.Lock()
+	defer h.releasesMutex.Unlock()
+
+	if installed {
+		h.releases[releaseName] = true
+	} else {
+		delete(h.releases, releaseName)
+	}
+}
+
+func (h *HelmClient) uninstallAll() error {
+	h.releasesMutex.Lock()
+	releaseNames := make([]string, 0, len(h.releases))
+	for releaseName := range h.releases {
+		releaseNames = append(releaseNames, releaseName)
+	}
+	h.releasesMutex.Unlock()
+	sort.Strings(releaseNames)
+
+	var errs []error
+	for _, releaseName := range releaseNames {
+		l.Log().Debugf("testcluster-go: Uninstalling helm release %s during test tear down", releaseName)
+		// the release may have been uninstalled otherwise or its installation failed early
+		errs = append(errs, h.uninstall(releaseName, true))
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadChart loads a chart from a directory or a packaged .tgz file.
+func loadChart(chartPath string) (*chart.Chart, error) {
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+
+	return chrt, nil
+}
+
+var _ genericclioptions.RESTClientGetter = &restClientGetter{}
+
+// restClientGetter provides the clients of the cluster to the Helm SDK.
+type restClientGetter struct {
+	restConfig *rest.Config
+	kubeConfig *api.Config
+	namespace  string
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.restConfig), nil
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(g.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	return memory.NewMemCacheClient(discoveryClient), nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	discoveryClient, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return restmapper.NewShortcutExpander(mapper, discoveryClient), nil
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	overrides := &clientcmd.ConfigOverrides{Context: api.Context{Namespace: g.namespace}}
+	return clientcmd.NewDefaultClientConfig(*g.kubeConfig, overrides)
+}
@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+const helloChart = "testdata/charts/hello"
+
+func fakeHelmConfig(t *testing.T) *action.Configuration {
+	return &action.Configuration{
+		Releases:     storage.Init(driver.NewMemory()),
+		KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          t.Logf,
+	}
+}
+
+func TestHelmClient(t *testing.T) {
+	t.Run("should install, upgrade and uninstall chart directory", func(t *testing.T) {
+		config := fakeHelmConfig(t)
+		helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, config, DefaultNamespace)
+
+		rel, err := helm.Install(testCtx, "hello", helloChart, nil)
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+		assert.Contains(t, rel.Manifest, `greeting: "hello"`)
+
+		rel, err = helm.Upgrade(testCtx, "hello", helloChart, map[string]any{"greeting": "moin"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, rel.Version)
+		assert.Contains(t, rel.Manifest, `greeting: "moin"`)
+
+		require.NoError(t, helm.Uninstall(testCtx, "hello"))
+		_, err = config.Releases.Deployed("hello")
+		assert.Error(t, err)
+	})
+	t.Run("should install packaged chart", func(t *testing.T) {
+		chrt, err := loadChart(helloChart)
+		require.NoError(t, err)
+		packaged, err := chartutil.Save(chrt, t.TempDir())
+		require.NoError(t, err)
+		helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, fakeHelmConfig(t), DefaultNamespace)
+
+		rel, err := helm.Install(testCtx, "packaged", packaged, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello", rel.Chart.Name())
+	})
+	t.Run("should fail on missing chart", func(t *testing.T) {
+		helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, fakeHelmConfig(t), DefaultNamespace)
+
+		_, err := helm.Install(testCtx, "missing", "testdata/charts/missing", nil)
+
+		assert.ErrorContains(t, err, "failed to load chart testdata/charts/missing")
+	})
+	t.Run("should uninstall remaining releases on cleanup", func(t *testing.T) {
+		config := fakeHelmConfig(t)
+
+		t.Run("test which installs a chart", func(t *testing.T) {
+			helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, config, DefaultNamespace)
+			_, err := helm.Install(testCtx, "hello", helloChart, nil)
+			require.NoError(t, err)
+		})
+
+		_, err := config.Releases.Deployed("hello")
+		assert.Error(t, err)
+	})
+}
+
+func TestHelmClient_cleanup(t *testing.T) {
+	t.Run("should uninstall release whose installation failed", func(t *testing.T) {
+		config := fakeHelmConfig(t)
+
+		t.Run("test which installs a chart", func(t *testing.T) {
+			helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, config, DefaultNamespace)
+			config.KubeClient = &kubefake.FailingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}, WaitError: assert.AnError}
+
+			_, err := helm.Install(testCtx, "hello", helloChart, nil)
+			require.Error(t, err)
+			config.KubeClient = &kubefake.PrintingKubeClient{Out: io.Discard}
+		})
+
+		_, err := config.Releases.History("hello")
+		assert.ErrorIs(t, err, driver.ErrReleaseNotFound)
+	})
+	t.Run("should not uninstall release of the same name which existed before", func(t *testing.T) {
+		config := fakeHelmConfig(t)
+		owner := newHelmClientWithConfig(t, NewFakeCluster(t), nil, config, DefaultNamespace)
+		_, err := owner.Install(testCtx, "hello", helloChart, nil)
+		require.NoError(t, err)
+
+		t.Run("test which installs a chart with the same name", func(t *testing.T) {
+			helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, config, DefaultNamespace)
+
+			_, err := helm.Install(testCtx, "hello", helloChart, nil)
+			require.ErrorContains(t, err, "cannot re-use a name that is still in use")
+		})
+
+		_, err = config.Releases.Deployed("hello")
+		assert.NoError(t, err)
+	})
+	t.Run("should ignore releases which are already uninstalled", func(t *testing.T) {
+		helm := newHelmClientWithConfig(t, NewFakeCluster(t), nil, fakeHelmConfig(t), DefaultNamespace)
+		_, err := helm.Install(testCtx, "hello", helloChart, nil)
+		require.NoError(t, err)
+		_, err = helm.config.Releases.Delete("hello", 1)
+		require.NoError(t, err)
+
+		assert.NoError(t, helm.uninstallAll())
+	})
+}
+
+func Test_newHelmClient(t *testing.T) {
+	_, err := newHelmClient(t, NewFakeCluster(t), nil, nil, DefaultNamespace)
+
+	assert.ErrorContains(t, err, "without kube config")
+}
+
+func Test_restClientGetter(t *testing.T) {
+	kubeConfig := api.NewConfig()
+	kubeConfig.Clusters["test"] = &api.Cluster{Server: "https://127.0.0.1:6443"}
+	kubeConfig.Contexts["test"] = &api.Context{Cluster: "test"}
+	kubeConfig.CurrentContext = "test"
+	getter := &restClientGetter{restConfig: &rest.Config{Host: "https://127.0.0.1:6443"}, kubeConfig: kubeConfig, namespace: "my-namespace"}
+
+	namespace, _, err := getter.ToRawKubeConfigLoader().Namespace()
+	require.NoError(t, err)
+	assert.Equal(t, "my-namespace", namespace)
+
+	restConfig, err := getter.ToRESTConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://127.0.0.1:6443", restConfig.Host)
+}
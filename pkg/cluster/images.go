@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/k3d-io/k3d/v5/pkg/client"
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+)
+
+const (
+	defaultImageRegistry  = "docker.io/"
+	defaultImageNamespace = "library/"
+	defaultImageTag       = ":latest"
+)
+
+// ImportImages loads the given images from the local container runtime into
+// every node of the cluster. This allows to test images that were built
+// locally but never pushed to a registry. Images may be referenced by tag or by
+// digest. Images referenced by digest are imported under that digest so that
+// pod specs can reference them the same way. An error is returned if one of the
+// images cannot be found in the local container runtime.
+func (c *K3dCluster) ImportImages(ctx context.Context, images ...string) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	runtimeTags, err := c.containerRuntime.GetImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images of the container runtime: %w", err)
+	}
+
+	tagged, digested, missing := splitImages(images, runtimeTags)
+	if len(missing) > 0 {
+		return fmt.Errorf("images not found in the local container runtime (were they built?): %s", strings.Join(missing, ", "))
+	}
+
+	if len(tagged) > 0 {
+		l.Log().Debugf("testcluster-go: importing images %s into cluster %s", strings.Join(tagged, ", "), c.ClusterName)
+		err = client.ImageImportIntoClusterMulti(ctx, c.containerRuntime, tagged, &c.clusterConfig.Cluster, k3dTypes.ImageImportOpts{})
+		if err != nil {
+			return fmt.Errorf("failed to import images into cluster %s: %w", c.ClusterName, err)
+		}
+	}
+
+	for _, image := range digested {
+		err = c.importDigestImage(ctx, image)
+		if err != nil {
+			return fmt.Errorf("failed to import image %s into cluster %s: %w", image, c.ClusterName, err)
+		}
+	}
+
+	return nil
+}
+
+// importDigestImage imports an image referenced by digest into every k3s node.
+// k3d only imports images by tag, and the tags of an image do not carry its
+// registry digest into the nodes. Therefore, the container runtime exports the
+// image by its registry digest and the nodes import it under the requested
+// reference.
+func (c *K3dCluster) importDigestImage(ctx context.Context, image string) error {
+	reference := withoutTag(image)
+	for _, node := range c.clusterConfig.Cluster.Nodes {
+		if node.Role != k3dTypes.ServerRole && node.Role != k3dTypes.AgentRole {
+			continue
+		}
+
+		l.Log().Debugf("testcluster-go: importing image %s into node %s", reference, node.Name)
+		stream, err := c.containerRuntime.GetImageStream(ctx, []string{reference})
+		if err != nil {
+			return fmt.Errorf("image not found in the local container runtime (was it pulled?): %w", err)
+		}
+
+		err = c.containerRuntime.ExecInNodeWithStdin(ctx, node, digestImageImportCommand(reference), stream)
+		if err != nil {
+			return fmt.Errorf("failed to import image into node %s: %w", node.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// digestImageImportCommand imports an image archive into containerd and names the
+// image after the requested digest. Image archives of digest references do not
+// contain any image name on their own.
+func digestImageImportCommand(reference string) []string {
+	return []string{"ctr", "image", "import", "--all-platforms", "--index-name", normalizeImageName(reference), "-"}
+}
+
+// splitImages separates the requested images into those which k3d imports by
+// one of the tags of the container runtime and those which are referenced by
+// digest. The container runtime resolves digests itself when the image is
+// exported, so only images without digest can be missing at this point.
+func splitImages(requested []string, runtimeTags []string) (tagged, digested, missing []string) {
+	tags := map[string]struct{}{}
+	for _, tag := range runtimeTags {
+		tags[normalizeImageName(tag)] = struct{}{}
+	}
+
+	for _, image := range requested {
+		if strings.Contains(image, "@") {
+			digested = append(digested, image)
+			continue
+		}
+
+		if _, found := tags[normalizeImageName(image)]; found {
+			tagged = append(tagged, image)
+			continue
+		}
+
+		missing = append(missing, image)
+	}
+
+	return tagged, digested, missing
+}
+
+// withoutTag removes the tag from image references which contain a tag and a
+// digest like "nginx:1.25@sha256:..." because registry digests do not contain tags.
+func withoutTag(image string) string {
+	digestStart := strings.Index(image, "@")
+	if digestStart < 0 {
+		return image
+	}
+
+	name := image[:digestStart]
+	if tagStart := strings.LastIndex(name, ":"); tagStart > strings.LastIndex(name, "/") {
+		name = name[:tagStart]
+	}
+
+	return name + image[digestStart:]
+}
+
+// normalizeImageName expands short image names like "nginx" to their full
+// reference "docker.io/library/nginx:latest" so that they can be compared.
+func normalizeImageName(image string) string {
+	nameStart := strings.LastIndex(image, "/") + 1
+	if !strings.ContainsAny(image[nameStart:], ":@") {
+		image += defaultImageTag
+	}
+
+	if !strings.Contains(image, "/") {
+		return defaultImageRegistry + defaultImageNamespace + image
+	}
+
+	firstPart := image[:strings.Index(image, "/")]
+	if !strings.ContainsAny(firstPart, ".:") && firstPart != "localhost" {
+		return defaultImageRegistry + image
+	}
+
+	return image
+}
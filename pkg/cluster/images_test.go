@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_normalizeImageName(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"bitnami/nginx", "docker.io/bitnami/nginx:latest"},
+		{"docker.io/library/nginx:1.25", "docker.io/library/nginx:1.25"},
+		{"my.registry:5000/team/app", "my.registry:5000/team/app:latest"},
+		{"localhost/app:dev", "localhost/app:dev"},
+		{"nginx@sha256:abcdef", "docker.io/library/nginx@sha256:abcdef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeImageName(tt.image))
+		})
+	}
+}
+
+func Test_splitImages(t *testing.T) {
+	runtimeTags := []string{"nginx:latest", "my.registry:5000/team/app:dev"}
+
+	tests := []struct {
+		name         string
+		requested    []string
+		wantTagged   []string
+		wantDigested []string
+		wantMissing  []string
+	}{
+		{"tags", []string{"docker.io/library/nginx", "my.registry:5000/team/app:dev"}, []string{"docker.io/library/nginx", "my.registry:5000/team/app:dev"}, nil, nil},
+		{"missing tag", []string{"my-app:dev"}, nil, nil, []string{"my-app:dev"}},
+		{"digest", []string{"docker.io/library/nginx@sha256:abcdef"}, nil, []string{"docker.io/library/nginx@sha256:abcdef"}, nil},
+		{"tag and digest", []string{"nginx:1.25@sha256:abcdef"}, nil, []string{"nginx:1.25@sha256:abcdef"}, nil},
+		{"digest without tag", []string{"untagged@sha256:123456"}, nil, []string{"untagged@sha256:123456"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tagged, digested, missing := splitImages(tt.requested, runtimeTags)
+
+			assert.Equal(t, tt.wantTagged, tagged)
+			assert.Equal(t, tt.wantDigested, digested)
+			assert.Equal(t, tt.wantMissing, missing)
+		})
+	}
+}
+
+// imageRecordingRuntime exports the images of imageIDs and records the commands
+// which import them into nodes. Other runtime calls panic.
+type imageRecordingRuntime struct {
+	runtimes.Runtime
+	imageIDs map[string]string
+	imports  map[string][]string
+	content  map[string]string
+}
+
+func (r *imageRecordingRuntime) GetImageStream(_ context.Context, images []string) (io.ReadCloser, error) {
+	id, ok := r.imageIDs[images[0]]
+	if !ok {
+		return nil, fmt.Errorf("reference does not exist")
+	}
+	return io.NopCloser(strings.NewReader(id)), nil
+}
+
+func (r *imageRecordingRuntime) ExecInNodeWithStdin(_ context.Context, node *k3dTypes.Node, cmd []string, stdin io.ReadCloser) error {
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	r.imports[node.Name] = cmd
+	r.content[node.Name] = string(content)
+	return nil
+}
+
+func TestK3dCluster_importDigestImage(t *testing.T) {
+	newCluster := func(containerRuntime runtimes.Runtime) *K3dCluster {
+		cluster := &K3dCluster{containerRuntime: containerRuntime, clusterConfig: &v1alpha5.ClusterConfig{}}
+		cluster.clusterConfig.Cluster.Nodes = []*k3dTypes.Node{
+			{Name: "server-0", Role: k3dTypes.ServerRole},
+			{Name: "agent-0", Role: k3dTypes.AgentRole},
+			{Name: "serverlb", Role: k3dTypes.LoadBalancerRole},
+		}
+		return cluster
+	}
+
+	t.Run("should import image under the requested digest", func(t *testing.T) {
+		containerRuntime := &imageRecordingRuntime{
+			imageIDs: map[string]string{"untagged@sha256:123456": "image"},
+			imports:  map[string][]string{},
+			content:  map[string]string{},
+		}
+
+		err := newCluster(containerRuntime).importDigestImage(testCtx, "untagged:1.0@sha256:123456")
+
+		require.NoError(t, err)
+		wantCmd := []string{"ctr", "image", "import", "--all-platforms", "--index-name", "docker.io/library/untagged@sha256:123456", "-"}
+		assert.Equal(t, map[string][]string{"server-0": wantCmd, "agent-0": wantCmd}, containerRuntime.imports)
+		assert.Equal(t, map[string]string{"server-0": "image", "agent-0": "image"}, containerRuntime.content)
+	})
+	t.Run("should fail on unknown digest", func(t *testing.T) {
+		containerRuntime := &imageRecordingRuntime{imageIDs: map[string]string{}, imports: map[string][]string{}, content: map[string]string{}}
+
+		err := newCluster(containerRuntime).importDigestImage(testCtx, "nginx@sha256:fedcba")
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "image not found in the local container runtime")
+		assert.Empty(t, containerRuntime.imports)
+	})
+}
@@ -0,0 +1,122 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KeepOnFailureEnvVar enables Opts.KeepOnFailure for all clusters of a test run
+// if set to true, f. i. TESTCLUSTERS_KEEP_ON_FAILURE=true go test ./...
+const KeepOnFailureEnvVar = "TESTCLUSTERS_KEEP_ON_FAILURE"
+
+// labelKeepOnFailure marks clusters which may outlive their test process on
+// purpose so that they will not be reaped.
+const labelKeepOnFailure = labelPrefix + "keep-on-failure"
+
+const keptKubeConfigMode = 0600
+
+func keepOnFailureEnabled(opts Opts) bool {
+	if opts.KeepOnFailure {
+		return true
+	}
+
+	enabled, _ := strconv.ParseBool(os.Getenv(KeepOnFailureEnvVar))
+	return enabled
+}
+
+// keepsOnFailure checks whether the cluster will be kept because the test failed.
+func keepsOnFailure(t testing.TB, cluster Cluster) bool {
+	k3dCluster, ok := cluster.(*K3dCluster)
+	return ok && k3dCluster.keepOnFailure && t.Failed()
+}
+
+// keepFailedCluster writes the kube config of the cluster to a stable path,
+// marks the cluster as kept for the reaper and tells the developer how to
+// inspect and delete the cluster.
+func keepFailedCluster(t testing.TB, cluster *K3dCluster) {
+	path := keptKubeConfigPath(cluster.ClusterName)
+	err := writeKeptKubeConfig(cluster, path)
+	if err != nil {
+		t.Errorf("testcluster-go: Failed to write kube config of kept cluster %s: %s", cluster.ClusterName, err.Error())
+		return
+	}
+
+	err = markKept(context.Background(), cluster.containerRuntime, cluster.ClusterName)
+	if err != nil {
+		t.Errorf("testcluster-go: Failed to mark kept cluster %s, so it may be reaped: %s", cluster.ClusterName, err.Error())
+	}
+
+	t.Logf("testcluster-go: Keeping cluster %s of the failed test. Inspect it with\n"+
+		"  KUBECONFIG=%s kubectl get pods --all-namespaces\n"+
+		"and delete it afterwards with\n"+
+		"  %sk3d cluster delete %s && rm %s",
+		cluster.ClusterName, path, runtimeEnvPrefix(), cluster.ClusterName, path)
+}
+
+// keptKubeConfigPath returns a path which outlives the test, unlike t.TempDir.
+func keptKubeConfigPath(clusterName string) string {
+	return filepath.Join(artifactsBaseDir(), clusterName+".kubeconfig")
+}
+
+// keptMarkerLabels identify the marker volume of a kept cluster. The k3d cluster
+// label lets k3d delete the marker along with the cluster.
+func keptMarkerLabels(clusterName string) map[string]string {
+	return map[string]string{
+		k3dTypes.LabelClusterName: clusterName,
+		labelKeepOnFailure:        "true",
+	}
+}
+
+// markKept creates the marker volume of a kept cluster. Docker cannot add
+// labels to the existing node containers, and files on the host depend on the
+// environment of the test process while the reaper may run elsewhere.
+func markKept(ctx context.Context, containerRuntime runtimes.Runtime, clusterName string) error {
+	return containerRuntime.CreateVolume(ctx, fmt.Sprintf("k3d-%s-kept", clusterName), keptMarkerLabels(clusterName))
+}
+
+// wasKept checks whether the cluster was kept after a failed test. Clusters
+// which are merely labelled for keeping but whose test was killed or passed
+// have no marker volume.
+func wasKept(ctx context.Context, containerRuntime runtimes.Runtime, clusterName string) (bool, error) {
+	volumes, err := containerRuntime.GetVolumesByLabel(ctx, keptMarkerLabels(clusterName))
+	if err != nil {
+		return false, fmt.Errorf("failed to look up marker volume of kept cluster %s: %w", clusterName, err)
+	}
+
+	return len(volumes) > 0, nil
+}
+
+func writeKeptKubeConfig(cluster *K3dCluster, path string) error {
+	if cluster.kubeConfig == nil {
+		return fmt.Errorf("cluster %s has no kube config", cluster.ClusterName)
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), artifactDirMode)
+	if err != nil {
+		return fmt.Errorf("failed to create directory for kube config %s: %w", path, err)
+	}
+
+	err = clientcmd.WriteToFile(*cluster.kubeConfig, path)
+	if err != nil {
+		return fmt.Errorf("failed to write kube config %s: %w", path, err)
+	}
+
+	return os.Chmod(path, keptKubeConfigMode)
+}
+
+// runtimeEnvPrefix returns the environment which k3d needs to find a container
+// runtime other than the default Docker socket, f. i. Podman.
+func runtimeEnvPrefix() string {
+	if dockerHost := os.Getenv(dockerHostEnvVar); dockerHost != "" {
+		return fmt.Sprintf("%s=%s ", dockerHostEnvVar, dockerHost)
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package cluster
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func Test_keepOnFailureEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Opts
+		envVar string
+		want   bool
+	}{
+		{"disabled by default", Opts{}, "", false},
+		{"enabled by option", Opts{KeepOnFailure: true}, "", true},
+		{"enabled by env var", Opts{}, "true", true},
+		{"invalid env var", Opts{}, "yes please", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(KeepOnFailureEnvVar, tt.envVar)
+			assert.Equal(t, tt.want, keepOnFailureEnabled(tt.opts))
+		})
+	}
+}
+
+func keptTestCluster(keepOnFailure bool) *K3dCluster {
+	kubeConfig := api.NewConfig()
+	kubeConfig.Clusters["k3d-kept"] = &api.Cluster{Server: "https://127.0.0.1:6443"}
+	kubeConfig.Contexts["k3d-kept"] = &api.Context{Cluster: "k3d-kept"}
+	kubeConfig.CurrentContext = "k3d-kept"
+
+	return &K3dCluster{
+		ClusterName:      "kept",
+		containerRuntime: &volumeRecordingRuntime{volumes: map[string]map[string]string{}},
+		clusterConfig:    &v1alpha5.ClusterConfig{},
+		kubeConfig:       kubeConfig,
+		keepOnFailure:    keepOnFailure,
+	}
+}
+
+func Test_registerTearDown_keepOnFailure(t *testing.T) {
+	t.Run("should keep cluster of failed test", func(t *testing.T) {
+		base := t.TempDir()
+		t.Setenv(ArtifactsDirEnvVar, base)
+		cluster := keptTestCluster(true)
+
+		t.Run("failing test", func(t *testing.T) {
+			// terminating the cluster would panic without container runtime
+			registerTearDown(failedT{t}, cluster)
+		})
+
+		actual, err := clientcmd.LoadFromFile(filepath.Join(base, "kept.kubeconfig"))
+		require.NoError(t, err)
+		assert.Equal(t, "k3d-kept", actual.CurrentContext)
+		assert.Equal(t, "https://127.0.0.1:6443", actual.Clusters["k3d-kept"].Server)
+		kept, err := wasKept(testCtx, cluster.containerRuntime, "kept")
+		require.NoError(t, err)
+		assert.True(t, kept)
+	})
+	t.Run("should not keep cluster of passed test", func(t *testing.T) {
+		assert.False(t, keepsOnFailure(t, keptTestCluster(true)))
+	})
+	t.Run("should not keep cluster without option", func(t *testing.T) {
+		assert.False(t, keepsOnFailure(failedT{t}, keptTestCluster(false)))
+	})
+	t.Run("should not keep other backends", func(t *testing.T) {
+		assert.False(t, keepsOnFailure(failedT{t}, NewFakeCluster(t)))
+	})
+}
+
+func Test_runtimeEnvPrefix(t *testing.T) {
+	t.Setenv(dockerHostEnvVar, "")
+	assert.Empty(t, runtimeEnvPrefix())
+
+	t.Setenv(dockerHostEnvVar, "unix:///run/user/1000/podman/podman.sock")
+	assert.Equal(t, "DOCKER_HOST=unix:///run/user/1000/podman/podman.sock ", runtimeEnvPrefix())
+}
@@ -0,0 +1,111 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomizeRoot is the directory where the file system is mounted for kustomize.
+const kustomizeRoot = "/"
+
+// ApplyKustomization builds the kustomization in the given directory of the file
+// system, like `kustomize build`, and applies the resulting resources. The build
+// runs in-process, so no kustomize binary is needed. Bases and components may
+// reside anywhere in the file system but not outside of it. Resources which get
+// a namespace from the kustomization, f. i. by its `namespace:` field, are
+// applied into that namespace. All other namespaced resources are applied into
+// the namespace of the YamlApplier like with ApplyWithFile.
+func (ya *YamlApplier) ApplyKustomization(ctx context.Context, fsys fs.FS, dir string) error {
+	rendered, err := buildKustomization(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	docs, err := splitYamlDocuments(rendered)
+	if err != nil {
+		return fmt.Errorf("failed to read resources of kustomization %s: %w", dir, err)
+	}
+
+	for i, doc := range docs {
+		namespace, err := documentNamespace(doc)
+		if err != nil {
+			return fmt.Errorf("failed to read namespace of document %d of kustomization %s: %w", i+1, dir, err)
+		}
+		if namespace == "" {
+			namespace = ya.defaultNamespace
+		}
+
+		err = ya.applyInNamespace(doc, namespace)
+		if err != nil {
+			return fmt.Errorf("failed to apply document %d of kustomization %s: %w\n%s", i+1, dir, err, string(doc))
+		}
+	}
+
+	return nil
+}
+
+// documentNamespace returns metadata.namespace of the document, which is empty
+// for cluster-scoped resources and resources without namespace.
+func documentNamespace(doc []byte) (string, error) {
+	var object metav1.PartialObjectMetadata
+	err := yaml.Unmarshal(doc, &object)
+	if err != nil {
+		return "", err
+	}
+
+	return object.Namespace, nil
+}
+
+func buildKustomization(fsys fs.FS, dir string) ([]byte, error) {
+	memFS, err := copyToMemFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(memFS, path.Join(kustomizeRoot, dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kustomization %s: %w", dir, err)
+	}
+
+	rendered, err := resources.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render resources of kustomization %s: %w", dir, err)
+	}
+
+	return rendered, nil
+}
+
+// copyToMemFS copies the file system into kustomize's in-memory file system so
+// that kustomize can read embedded files.
+func copyToMemFS(fsys fs.FS) (filesys.FileSystem, error) {
+	memFS := filesys.MakeFsInMemory()
+
+	err := fs.WalkDir(fsys, ".", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(kustomizeRoot, file)
+		if entry.IsDir() {
+			return memFS.MkdirAll(target)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		return memFS.WriteFile(target, content)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kustomization files: %w", err)
+	}
+
+	return memFS, nil
+}
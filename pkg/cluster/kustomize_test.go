@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var kustomizeFS = fstest.MapFS{
+	"deploy/base/kustomization.yaml": {Data: []byte(`resources:
+  - configmap.yaml
+`)},
+	"deploy/base/configmap.yaml": {Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  logLevel: info
+`)},
+	"deploy/overlays/test/kustomization.yaml": {Data: []byte(`resources:
+  - ../../base
+namePrefix: test-
+patches:
+  - path: loglevel.yaml
+`)},
+	"deploy/overlays/test/loglevel.yaml": {Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  logLevel: debug
+`)},
+	"deploy/overlays/namespaced/kustomization.yaml": {Data: []byte(`resources:
+  - ../../base
+namespace: my-namespace
+`)},
+	"deploy/overlays/broken/kustomization.yaml": {Data: []byte(`resources:
+  - ../missing
+`)},
+}
+
+func TestYamlApplier_ApplyKustomization(t *testing.T) {
+	t.Run("should apply overlay", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyKustomization(testCtx, kustomizeFS, "deploy/overlays/test")
+
+		require.NoError(t, err)
+		actual, err := cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "test-app-config", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "debug", actual.Data["logLevel"])
+	})
+	t.Run("should apply into namespace of overlay", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+
+		t.Run("test which applies an overlay", func(t *testing.T) {
+			kubectl, err := cl.CtlKube("test")
+			require.NoError(t, err)
+
+			err = kubectl.WithCleanup(t).ApplyKustomization(testCtx, kustomizeFS, "deploy/overlays/namespaced")
+
+			require.NoError(t, err)
+			_, err = cl.FakeClientSet().CoreV1().ConfigMaps("my-namespace").Get(testCtx, "app-config", metav1.GetOptions{})
+			require.NoError(t, err)
+			_, err = cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "app-config", metav1.GetOptions{})
+			assert.Error(t, err)
+		})
+
+		_, err := cl.FakeClientSet().CoreV1().ConfigMaps("my-namespace").Get(testCtx, "app-config", metav1.GetOptions{})
+		assert.Error(t, err, "config map should be deleted from the namespace of the overlay")
+	})
+	t.Run("should fail on invalid kustomization", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyKustomization(testCtx, kustomizeFS, "deploy/overlays/broken")
+
+		assert.ErrorContains(t, err, "failed to build kustomization deploy/overlays/broken")
+	})
+}
@@ -7,7 +7,7 @@ import (
 
 // Lookout provides convenience functionalities for cluster resources.
 type Lookout struct {
-	t *testing.T
+	t testing.TB
 	c kubernetes.Interface
 }
 
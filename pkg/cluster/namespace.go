@@ -0,0 +1,201 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	v1 "k8s.io/api/core/v1"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/test-clusters/testclusters-go/pkg/naming"
+)
+
+const (
+	// testNameAnnotation contains the name of the test that created a namespace.
+	testNameAnnotation = "k3s.test-name"
+	// maxNamespacePrefixLength leaves room for the generated namespace suffix
+	// within the 63 characters of an RFC 1123 label.
+	maxNamespacePrefixLength = 40
+	fallbackNamespacePrefix  = "test"
+	namespaceDeletionTimeout = 2 * time.Minute
+)
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Namespace is a uniquely named K8s namespace which belongs to a single test.
+// It allows several tests to share the same cluster without stepping on each
+// other's resources.
+type Namespace struct {
+	// Name contains the generated namespace name.
+	Name string
+	// Kubectl applies YAML resources into this namespace.
+	Kubectl *YamlApplier
+	// Lookout provides access to cluster resources. Use Pods and Pod to address
+	// the pods of this namespace.
+	Lookout *Lookout
+}
+
+// Pods returns a PodListSelector to address multiple pods in this namespace.
+func (n *Namespace) Pods() *PodListSelector {
+	return n.Lookout.Pods(n.Name)
+}
+
+// Pod returns a PodSelector to address a single pod in this namespace.
+func (n *Namespace) Pod(name string) *PodSelector {
+	return n.Lookout.Pod(n.Name, name)
+}
+
+// Namespace creates a new namespace with a unique name for the given test. The
+// namespace will be deleted once the test finishes.
+func (c *K3dCluster) Namespace(t testing.TB) *Namespace {
+	t.Helper()
+	return newNamespace(t, c, restConfigApplierFactory(c.clientConfig))
+}
+
+// yamlApplierFactory creates a YamlApplier for the given namespace.
+type yamlApplierFactory func(fieldManager, namespace string) (*YamlApplier, error)
+
+func restConfigApplierFactory(restConfig *rest.Config) yamlApplierFactory {
+	return func(fieldManager, namespace string) (*YamlApplier, error) {
+		return NewYamlApplier(restConfig, fieldManager, namespace)
+	}
+}
+
+func newNamespace(t testing.TB, c Cluster, newApplier yamlApplierFactory) *Namespace {
+	t.Helper()
+	ctx := context.Background()
+
+	clientSet, err := c.ClientSet()
+	if err != nil {
+		t.Fatalf("testcluster-go: namespace could not build clientSet for cluster: %s", err.Error())
+	}
+
+	name, err := createTestNamespace(ctx, clientSet, t.Name())
+	if err != nil {
+		t.Fatalf("testcluster-go: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		dumpDiagnosticsOnFailure(t, c, name)
+		if keepsOnFailure(t, c) {
+			l.Log().Infof("testcluster-go: Keeping namespace %s of the failed test", name)
+			return
+		}
+
+		l.Log().Debugf("testcluster-go: Deleting namespace %s during test tear down", name)
+		err := deleteNamespace(context.Background(), clientSet, name)
+		if err != nil {
+			t.Errorf("Unexpected error during namespace tear down: %s\n", err.Error())
+		}
+	})
+
+	kubectl, err := newApplier(t.Name(), name)
+	if err != nil {
+		t.Fatalf("testcluster-go: namespace could not build applier: %s", err.Error())
+	}
+
+	lookout, err := c.Lookout(t)
+	if err != nil {
+		t.Fatalf("testcluster-go: %s", err.Error())
+	}
+
+	return &Namespace{
+		Name:    name,
+		Kubectl: kubectl,
+		Lookout: lookout,
+	}
+}
+
+func createTestNamespace(ctx context.Context, clientSet kubernetes.Interface, testName string) (string, error) {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        naming.MustGenerateK8sName(namespacePrefix(testName)),
+			Labels:      map[string]string{creatorLabel: appName, labelPID: strconv.Itoa(os.Getpid())},
+			Annotations: map[string]string{testNameAnnotation: testName},
+		},
+	}
+
+	ns, err := clientSet.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create namespace for test %s: %w", testName, err)
+	}
+
+	l.Log().Debugf("testcluster-go: created namespace %s for test %s", ns.Name, testName)
+	return ns.Name, nil
+}
+
+// deleteNamespace deletes the given namespace and waits until the namespace has
+// been terminated.
+func deleteNamespace(ctx context.Context, clientSet kubernetes.Interface, name string) error {
+	err := clientSet.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		if k8sErrs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete namespace %s: %w", name, err)
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, namespaceDeletionTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := clientSet.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if k8sErrs.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("waited too long for namespace %s to terminate: %w", name, err)
+	}
+
+	return nil
+}
+
+// wipeTestNamespaces deletes the test namespaces which were left behind by
+// other test processes, f. i. in a reused cluster.
+func wipeTestNamespaces(ctx context.Context, clientSet kubernetes.Interface) error {
+	selector := fmt.Sprintf("%s=%s,%s!=%d", creatorLabel, appName, labelPID, os.Getpid())
+	return deleteNamespacesBySelector(ctx, clientSet, selector)
+}
+
+// deleteNamespacesBySelector deletes all namespaces which match the given label
+// selector and waits until they have been terminated.
+func deleteNamespacesBySelector(ctx context.Context, clientSet kubernetes.Interface, selector string) error {
+	namespaces, err := clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("failed to list test namespaces: %w", err)
+	}
+
+	for _, ns := range namespaces.Items {
+		l.Log().Debugf("testcluster-go: wiping namespace %s of test %s", ns.Name, ns.Annotations[testNameAnnotation])
+		err = deleteNamespace(ctx, clientSet, ns.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// namespacePrefix turns a test name into a RFC 1123 compatible namespace name prefix.
+func namespacePrefix(testName string) string {
+	prefix := invalidNamespaceChars.ReplaceAllString(strings.ToLower(testName), "-")
+	if len(prefix) > maxNamespacePrefixLength {
+		prefix = prefix[:maxNamespacePrefixLength]
+	}
+	prefix = strings.Trim(prefix, "-")
+
+	if prefix == "" {
+		return fallbackNamespacePrefix
+	}
+	return prefix
+}
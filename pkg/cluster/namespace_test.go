@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_namespacePrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		testName string
+		want     string
+	}{
+		{"lower-cases test name", "TestNamespace", "testnamespace"},
+		{"replaces sub-test delimiter", "TestNamespace/sub_test", "testnamespace-sub-test"},
+		{"trims delimiters", "_TestNamespace_", "testnamespace"},
+		{"truncates long test names", "TestAVeryLongTestNameThatWouldNotFitIntoANamespaceName", "testaverylongtestnamethatwouldnotfitinto"},
+		{"falls back on empty name", "ÜŞ$", "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, namespacePrefix(tt.testName))
+		})
+	}
+}
+
+func Test_createTestNamespace(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+
+	name, err := createTestNamespace(testCtx, clientSet, "TestSomething")
+
+	require.NoError(t, err)
+	assert.Regexp(t, "^testsomething-[a-f0-9]{8}$", name)
+	ns, err := clientSet.CoreV1().Namespaces().Get(testCtx, name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, appName, ns.Labels[creatorLabel])
+	assert.Equal(t, "TestSomething", ns.Annotations[testNameAnnotation])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), ns.Labels[labelPID])
+}
+
+func Test_deleteNamespace(t *testing.T) {
+	t.Run("should delete namespace", func(t *testing.T) {
+		clientSet := fake.NewSimpleClientset()
+		name, err := createTestNamespace(testCtx, clientSet, "TestSomething")
+		require.NoError(t, err)
+
+		err = deleteNamespace(testCtx, clientSet, name)
+
+		require.NoError(t, err)
+		_, err = clientSet.CoreV1().Namespaces().Get(testCtx, name, metav1.GetOptions{})
+		assert.True(t, k8sErrs.IsNotFound(err))
+	})
+	t.Run("should ignore missing namespace", func(t *testing.T) {
+		clientSet := fake.NewSimpleClientset()
+
+		err := deleteNamespace(testCtx, clientSet, "does-not-exist")
+
+		require.NoError(t, err)
+	})
+}
+
+func Test_wipeTestNamespaces(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	clientSet := fake.NewSimpleClientset(
+		namespace("previous-run", map[string]string{creatorLabel: appName, labelPID: "1"}),
+		namespace("current-run", map[string]string{creatorLabel: appName, labelPID: strconv.Itoa(os.Getpid())}),
+		namespace("kube-system", nil),
+	)
+
+	err := wipeTestNamespaces(testCtx, clientSet)
+
+	require.NoError(t, err)
+	namespaces, err := clientSet.CoreV1().Namespaces().List(testCtx, metav1.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	assert.ElementsMatch(t, []string{"current-run", "kube-system"}, names)
+}
@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultServerCount = 1
+
+// allNodesFilter selects all K3s nodes (but not k3d's load balancer) in k3d's node filter syntax.
+var allNodesFilter = []string{"server:*", "agent:*"}
+
+// NodeLabel describes a K8s label which will be added to the cluster nodes
+// selected by the node filters.
+type NodeLabel struct {
+	// Key contains the label key, f. i. "topology.kubernetes.io/zone".
+	Key string
+	// Value contains the label value, f. i. "zone-a".
+	Value string
+	// NodeFilters select the nodes in k3d's node filter syntax, f. i. "server:0" or "agent:0,1".
+	// Defaults to all server and agent nodes.
+	NodeFilters []string
+}
+
+// NodeTaint describes a K8s taint which will be added to the cluster nodes
+// selected by the node filters.
+type NodeTaint struct {
+	// Key contains the taint key.
+	Key string
+	// Value contains the optional taint value.
+	Value string
+	// Effect contains the taint effect, f. i. v1.TaintEffectNoSchedule.
+	Effect v1.TaintEffect
+	// NodeFilters select the nodes in k3d's node filter syntax, f. i. "server:0" or "agent:0,1".
+	// Defaults to all server and agent nodes.
+	NodeFilters []string
+}
+
+// nodeCounts returns the number of server and agent nodes with applied defaults.
+func nodeCounts(opts Opts) (servers int, agents int, err error) {
+	if opts.Servers < 0 || opts.Agents < 0 {
+		return 0, 0, fmt.Errorf("node counts must not be negative (servers: %d, agents: %d)", opts.Servers, opts.Agents)
+	}
+
+	servers = opts.Servers
+	if servers == 0 {
+		servers = defaultServerCount
+	}
+
+	return servers, opts.Agents, nil
+}
+
+func k3sNodeLabels(labels []NodeLabel) ([]v1alpha5.LabelWithNodeFilters, error) {
+	var result []v1alpha5.LabelWithNodeFilters
+	for _, label := range labels {
+		if label.Key == "" {
+			return nil, fmt.Errorf("node label key must not be empty (value: '%s')", label.Value)
+		}
+
+		result = append(result, v1alpha5.LabelWithNodeFilters{
+			Label:       fmt.Sprintf("%s=%s", label.Key, label.Value),
+			NodeFilters: nodeFiltersOrAll(label.NodeFilters),
+		})
+	}
+
+	return result, nil
+}
+
+func k3sNodeTaintArgs(taints []NodeTaint) ([]v1alpha5.K3sArgWithNodeFilters, error) {
+	var result []v1alpha5.K3sArgWithNodeFilters
+	for _, taint := range taints {
+		if taint.Key == "" {
+			return nil, fmt.Errorf("node taint key must not be empty (value: '%s')", taint.Value)
+		}
+
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("unsupported effect '%s' for node taint %s", taint.Effect, taint.Key)
+		}
+
+		result = append(result, v1alpha5.K3sArgWithNodeFilters{
+			Arg:         fmt.Sprintf("--node-taint=%s=%s:%s", taint.Key, taint.Value, taint.Effect),
+			NodeFilters: nodeFiltersOrAll(taint.NodeFilters),
+		})
+	}
+
+	return result, nil
+}
+
+// k3sNodeCount returns the number of server and agent nodes which will join the K8s cluster.
+func k3sNodeCount(clusterConfig *v1alpha5.ClusterConfig) int {
+	count := 0
+	for _, node := range clusterConfig.Cluster.Nodes {
+		if node.Role == k3dTypes.ServerRole || node.Role == k3dTypes.AgentRole {
+			count++
+		}
+	}
+	return count
+}
+
+func nodeFiltersOrAll(nodeFilters []string) []string {
+	if len(nodeFilters) == 0 {
+		return allNodesFilter
+	}
+	return nodeFilters
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+)
+
+func Test_nodeCounts(t *testing.T) {
+	t.Run("should default to a single server", func(t *testing.T) {
+		servers, agents, err := nodeCounts(Opts{})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, servers)
+		assert.Equal(t, 0, agents)
+	})
+	t.Run("should use configured counts", func(t *testing.T) {
+		servers, agents, err := nodeCounts(Opts{Servers: 3, Agents: 2})
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, servers)
+		assert.Equal(t, 2, agents)
+	})
+	t.Run("should fail on negative counts", func(t *testing.T) {
+		_, _, err := nodeCounts(Opts{Agents: -1})
+
+		require.Error(t, err)
+	})
+}
+
+func Test_k3sNodeLabels(t *testing.T) {
+	t.Run("should convert labels", func(t *testing.T) {
+		labels := []NodeLabel{
+			{Key: "topology.kubernetes.io/zone", Value: "zone-a", NodeFilters: []string{"agent:0"}},
+			{Key: "disktype", Value: "ssd"},
+		}
+
+		actual, err := k3sNodeLabels(labels)
+
+		require.NoError(t, err)
+		expected := []v1alpha5.LabelWithNodeFilters{
+			{Label: "topology.kubernetes.io/zone=zone-a", NodeFilters: []string{"agent:0"}},
+			{Label: "disktype=ssd", NodeFilters: []string{"server:*", "agent:*"}},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on empty key", func(t *testing.T) {
+		_, err := k3sNodeLabels([]NodeLabel{{Value: "ssd"}})
+
+		require.Error(t, err)
+	})
+}
+
+func Test_k3sNodeTaintArgs(t *testing.T) {
+	t.Run("should convert taints", func(t *testing.T) {
+		taints := []NodeTaint{{Key: "dedicated", Value: "db", Effect: v1.TaintEffectNoSchedule, NodeFilters: []string{"agent:1"}}}
+
+		actual, err := k3sNodeTaintArgs(taints)
+
+		require.NoError(t, err)
+		expected := []v1alpha5.K3sArgWithNodeFilters{{Arg: "--node-taint=dedicated=db:NoSchedule", NodeFilters: []string{"agent:1"}}}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should fail on unsupported effect", func(t *testing.T) {
+		_, err := k3sNodeTaintArgs([]NodeTaint{{Key: "dedicated", Effect: "Sometimes"}})
+
+		require.Error(t, err)
+	})
+}
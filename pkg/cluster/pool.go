@@ -0,0 +1,280 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+)
+
+const (
+	defaultPoolSize              = 2
+	defaultPoolParallelCreations = 2
+	poolTestName                 = "Pool"
+)
+
+// PoolOpts configures a Pool.
+type PoolOpts struct {
+	// Size sets the number of clusters which will be created in the background.
+	// Defaults to 2.
+	Size int
+	// MaxParallelCreations limits how many clusters are created at the same time
+	// so that the container runtime is not overloaded.
+	// Defaults to 2.
+	MaxParallelCreations int
+	// ClusterOpts customizes every cluster of the pool. Reuse is not supported.
+	ClusterOpts Opts
+}
+
+// PoolStats reports how long tests waited for a pool cluster.
+type PoolStats struct {
+	// Acquired contains the number of handed out clusters.
+	Acquired int
+	// TotalWait contains the summed up wait time of all Acquire calls.
+	TotalWait time.Duration
+	// MaxWait contains the longest wait time of a single Acquire call.
+	MaxWait time.Duration
+}
+
+type poolEntry struct {
+	cluster *K3dCluster
+	err     error
+}
+
+// Pool holds pre-warmed clusters for parallel tests. The clusters are started in
+// the background when the pool is created so that tests do not need to wait for
+// a cluster creation each. Create the pool in TestMain and Close it after all
+// tests ran:
+//
+//	var pool *cluster.Pool
+//
+//	func TestMain(m *testing.M) {
+//		pool, _ = cluster.NewPool(cluster.PoolOpts{Size: 4})
+//		code := m.Run()
+//		_ = pool.Close(context.Background())
+//		os.Exit(code)
+//	}
+type Pool struct {
+	ready     chan poolEntry
+	closed    chan struct{}
+	closeOnce sync.Once
+	creations sync.WaitGroup
+	throttle  chan struct{}
+
+	mutex    sync.Mutex
+	clusters []*K3dCluster
+	stats    PoolStats
+
+	create    func(ctx context.Context) (*K3dCluster, error)
+	reset     func(ctx context.Context, cluster *K3dCluster) error
+	terminate func(ctx context.Context, cluster *K3dCluster) error
+}
+
+// NewPool starts to create the pool's clusters in the background and returns
+// immediately.
+func NewPool(opts PoolOpts) (*Pool, error) {
+	if reuseEnabled(opts.ClusterOpts) {
+		return nil, fmt.Errorf("reusable clusters cannot be pooled (check Opts.Reuse and %s)", ReuseEnvVar)
+	}
+
+	clusterOpts := opts.ClusterOpts
+	clusterOpts.testName = poolTestName
+
+	return newPool(opts,
+		func(ctx context.Context) (*K3dCluster, error) {
+			return CreateK3dCluster(ctx, clusterOpts)
+		},
+		resetPoolCluster,
+		func(ctx context.Context, cluster *K3dCluster) error {
+			return cluster.Terminate(ctx)
+		},
+	)
+}
+
+func newPool(opts PoolOpts,
+	create func(ctx context.Context) (*K3dCluster, error),
+	reset func(ctx context.Context, cluster *K3dCluster) error,
+	terminate func(ctx context.Context, cluster *K3dCluster) error,
+) (*Pool, error) {
+	size := opts.Size
+	if size == 0 {
+		size = defaultPoolSize
+	}
+	parallelCreations := opts.MaxParallelCreations
+	if parallelCreations == 0 {
+		parallelCreations = defaultPoolParallelCreations
+	}
+	if size < 0 || parallelCreations < 0 {
+		return nil, fmt.Errorf("pool size (%d) and parallel creations (%d) must not be negative", size, parallelCreations)
+	}
+
+	p := &Pool{
+		ready:     make(chan poolEntry, size),
+		closed:    make(chan struct{}),
+		throttle:  make(chan struct{}, parallelCreations),
+		create:    create,
+		reset:     reset,
+		terminate: terminate,
+	}
+
+	for i := 0; i < size; i++ {
+		p.startCreation()
+	}
+
+	return p, nil
+}
+
+func (p *Pool) startCreation() {
+	p.creations.Add(1)
+	go func() {
+		defer p.creations.Done()
+
+		p.throttle <- struct{}{}
+		defer func() { <-p.throttle }()
+
+		start := time.Now()
+		cluster, err := p.create(context.Background())
+		if err != nil {
+			l.Log().Errorf("testcluster-go: Failed to create pool cluster: %s", err.Error())
+			p.ready <- poolEntry{err: fmt.Errorf("failed to create pool cluster: %w", err)}
+			return
+		}
+		l.Log().Infof("testcluster-go: Pool cluster %s is ready after %s", cluster.ClusterName, time.Since(start).Round(time.Millisecond))
+
+		p.mutex.Lock()
+		p.clusters = append(p.clusters, cluster)
+		p.mutex.Unlock()
+
+		p.ready <- poolEntry{cluster: cluster}
+	}()
+}
+
+// Acquire waits for a ready cluster and hands it out exclusively to the given
+// test. The cluster will be reset and released back into the pool once the test
+// finishes. Resetting deletes all test namespaces, so tests should create their
+// resources in their own Namespace. The test fails if the pool is closed or the
+// cluster could not be created. A cluster which could not be created is
+// replaced by a new one for the following tests.
+func (p *Pool) Acquire(t testing.TB) *K3dCluster {
+	t.Helper()
+
+	start := time.Now()
+	var entry poolEntry
+	select {
+	case entry = <-p.ready:
+	case <-p.closed:
+		t.Fatalf("testcluster-go: Cannot acquire cluster from closed pool")
+		return nil
+	}
+
+	if entry.err != nil {
+		// replace the failed cluster so that the pool keeps its size for other tests
+		select {
+		case <-p.closed:
+		default:
+			p.startCreation()
+		}
+		t.Fatalf("testcluster-go: Unexpected error during test setup: %s", entry.err.Error())
+		return nil
+	}
+
+	wait := time.Since(start)
+	p.recordWait(wait)
+	t.Logf("testcluster-go: Acquired cluster %s from pool after %s", entry.cluster.ClusterName, wait.Round(time.Millisecond))
+
+	t.Cleanup(func() {
+		// the cluster is reset afterwards, so this is the last chance to see what went wrong
+		dumpDiagnosticsOnFailure(t, entry.cluster, "")
+		p.release(entry.cluster)
+	})
+
+	return entry.cluster
+}
+
+func (p *Pool) recordWait(wait time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stats.Acquired++
+	p.stats.TotalWait += wait
+	if wait > p.stats.MaxWait {
+		p.stats.MaxWait = wait
+	}
+}
+
+// release resets the cluster and puts it back into the pool. Clusters which
+// cannot be reset are replaced by a new cluster.
+func (p *Pool) release(cluster *K3dCluster) {
+	ctx := context.Background()
+
+	err := p.reset(ctx, cluster)
+	if err == nil {
+		p.ready <- poolEntry{cluster: cluster}
+		return
+	}
+
+	l.Log().Warnf("testcluster-go: Replacing pool cluster %s which could not be reset: %s", cluster.ClusterName, err.Error())
+	p.mutex.Lock()
+	for i, c := range p.clusters {
+		if c == cluster {
+			p.clusters = append(p.clusters[:i], p.clusters[i+1:]...)
+			break
+		}
+	}
+	p.mutex.Unlock()
+
+	err = p.terminate(ctx, cluster)
+	if err != nil {
+		l.Log().Errorf("testcluster-go: Failed to terminate pool cluster %s: %s", cluster.ClusterName, err.Error())
+	}
+
+	select {
+	case <-p.closed:
+	default:
+		p.startCreation()
+	}
+}
+
+// Stats returns the wait times of all Acquire calls so far.
+func (p *Pool) Stats() PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.stats
+}
+
+// Close waits for clusters which are still being created and terminates all
+// clusters of the pool. Close must be called after all tests finished.
+func (p *Pool) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+	})
+	p.creations.Wait()
+
+	p.mutex.Lock()
+	clusters := p.clusters
+	p.clusters = nil
+	stats := p.stats
+	p.mutex.Unlock()
+
+	l.Log().Infof("testcluster-go: Closing pool after handing out %d clusters (total wait: %s, max wait: %s)",
+		stats.Acquired, stats.TotalWait.Round(time.Millisecond), stats.MaxWait.Round(time.Millisecond))
+
+	var errs []error
+	for _, cluster := range clusters {
+		err := p.terminate(ctx, cluster)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to terminate pool cluster %s: %w", cluster.ClusterName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func resetPoolCluster(ctx context.Context, cluster *K3dCluster) error {
+	return deleteNamespacesBySelector(ctx, cluster.clientSet, fmt.Sprintf("%s=%s", creatorLabel, appName))
+}
@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePoolClusters struct {
+	created    atomic.Int32
+	running    atomic.Int32
+	maxRunning atomic.Int32
+	createErr  error
+	// failures is the number of creations which fail before creations succeed.
+	failures atomic.Int32
+	resetErr error
+
+	mutex      sync.Mutex
+	reset      []string
+	terminated []string
+}
+
+func (f *fakePoolClusters) create(context.Context) (*K3dCluster, error) {
+	running := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		maxRunning := f.maxRunning.Load()
+		if running <= maxRunning || f.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	if f.failures.Add(-1) >= 0 {
+		return nil, assert.AnError
+	}
+	return &K3dCluster{ClusterName: fmt.Sprintf("cluster-%d", f.created.Add(1))}, nil
+}
+
+func (f *fakePoolClusters) resetCluster(_ context.Context, cluster *K3dCluster) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.reset = append(f.reset, cluster.ClusterName)
+	return f.resetErr
+}
+
+func (f *fakePoolClusters) terminate(_ context.Context, cluster *K3dCluster) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.terminated = append(f.terminated, cluster.ClusterName)
+	return nil
+}
+
+// fatalT records a fatal test failure without stopping the test.
+type fatalT struct {
+	testing.TB
+	fatal string
+}
+
+func (f *fatalT) Fatalf(format string, args ...any) {
+	f.fatal = fmt.Sprintf(format, args...)
+}
+
+func newFakePool(t *testing.T, opts PoolOpts, fake *fakePoolClusters) *Pool {
+	pool, err := newPool(opts, fake.create, fake.resetCluster, fake.terminate)
+	require.NoError(t, err)
+	return pool
+}
+
+func TestPool_Acquire(t *testing.T) {
+	t.Run("should hand out and reset clusters", func(t *testing.T) {
+		fake := &fakePoolClusters{}
+		pool := newFakePool(t, PoolOpts{Size: 1}, fake)
+
+		var first, second *K3dCluster
+		t.Run("first", func(t *testing.T) { first = pool.Acquire(t) })
+		t.Run("second", func(t *testing.T) { second = pool.Acquire(t) })
+
+		assert.Same(t, first, second)
+		assert.Equal(t, []string{"cluster-1", "cluster-1"}, fake.reset)
+		assert.Equal(t, 2, pool.Stats().Acquired)
+		require.NoError(t, pool.Close(testCtx))
+		assert.Equal(t, []string{"cluster-1"}, fake.terminated)
+	})
+	t.Run("should replace clusters which cannot be reset", func(t *testing.T) {
+		fake := &fakePoolClusters{resetErr: assert.AnError}
+		pool := newFakePool(t, PoolOpts{Size: 1}, fake)
+
+		var first, second *K3dCluster
+		t.Run("first", func(t *testing.T) { first = pool.Acquire(t) })
+		t.Run("second", func(t *testing.T) { second = pool.Acquire(t) })
+
+		assert.Equal(t, "cluster-1", first.ClusterName)
+		assert.Equal(t, "cluster-2", second.ClusterName)
+		require.NoError(t, pool.Close(testCtx))
+		// the replacement of the second cluster is terminated as well
+		assert.ElementsMatch(t, []string{"cluster-1", "cluster-2", "cluster-3"}, fake.terminated)
+	})
+	t.Run("should replace clusters which could not be created", func(t *testing.T) {
+		fake := &fakePoolClusters{}
+		fake.failures.Store(1)
+		pool := newFakePool(t, PoolOpts{Size: 1}, fake)
+
+		failed := &fatalT{TB: t}
+		assert.Nil(t, pool.Acquire(failed))
+		assert.Contains(t, failed.fatal, "failed to create pool cluster")
+
+		var acquired *K3dCluster
+		t.Run("next", func(t *testing.T) { acquired = pool.Acquire(t) })
+		require.NotNil(t, acquired)
+		assert.Equal(t, "cluster-1", acquired.ClusterName)
+		require.NoError(t, pool.Close(testCtx))
+		assert.Equal(t, []string{"cluster-1"}, fake.terminated)
+	})
+}
+
+func TestPool_creation(t *testing.T) {
+	t.Run("should limit parallel creations", func(t *testing.T) {
+		fake := &fakePoolClusters{}
+		pool := newFakePool(t, PoolOpts{Size: 6, MaxParallelCreations: 2}, fake)
+
+		require.NoError(t, pool.Close(testCtx))
+
+		assert.Equal(t, int32(6), fake.created.Load())
+		assert.Equal(t, int32(2), fake.maxRunning.Load())
+		assert.Len(t, fake.terminated, 6)
+	})
+	t.Run("should report creation errors", func(t *testing.T) {
+		fake := &fakePoolClusters{createErr: assert.AnError}
+		pool := newFakePool(t, PoolOpts{Size: 1}, fake)
+		pool.creations.Wait()
+
+		entry := <-pool.ready
+		pool.ready <- entry
+
+		require.Error(t, entry.err)
+		assert.ErrorIs(t, entry.err, assert.AnError)
+		require.NoError(t, pool.Close(testCtx))
+	})
+	t.Run("should fail on negative size", func(t *testing.T) {
+		_, err := newPool(PoolOpts{Size: -1}, nil, nil, nil)
+
+		require.Error(t, err)
+	})
+}
+
+func TestNewPool(t *testing.T) {
+	t.Run("should refuse reusable clusters", func(t *testing.T) {
+		_, err := NewPool(PoolOpts{ClusterOpts: Opts{Reuse: true}})
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "cannot be pooled")
+	})
+}
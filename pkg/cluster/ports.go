@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"fmt"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/phayes/freeport"
+)
+
+const (
+	loadBalancerNodeFilter = "loadbalancer"
+	portHostAddress        = "localhost"
+)
+
+// PortMapping publishes a cluster port on the host so that tests can reach
+// cluster services (f. i. via Traefik or ServiceLB) from the test process.
+type PortMapping struct {
+	// ContainerPort contains the port that should be published, f. i. 80 for
+	// Traefik's web entrypoint or the port of a LoadBalancer service.
+	ContainerPort int
+	// HostPort contains the port on the host.
+	// Defaults to a random free port.
+	HostPort int
+	// NodeFilters select the nodes in k3d's node filter syntax.
+	// Defaults to k3d's load balancer which forwards to all nodes.
+	NodeFilters []string
+}
+
+// HostURL returns the URL under which the given published container port can
+// be reached from the host, f. i. "http://localhost:43567". The port must have
+// been published with Opts.Ports.
+func (c *K3dCluster) HostURL(port int) (string, error) {
+	for _, node := range c.clusterConfig.Cluster.Nodes {
+		for natPort, bindings := range node.Ports {
+			if natPort.Int() != port || natPort.Proto() != "tcp" || len(bindings) == 0 {
+				continue
+			}
+			return fmt.Sprintf("http://%s:%s", portHostAddress, bindings[0].HostPort), nil
+		}
+	}
+
+	return "", fmt.Errorf("port %d is not published on the host for cluster %s (see Opts.Ports)", port, c.ClusterName)
+}
+
+func createPortConfig(mappings []PortMapping) ([]v1alpha5.PortWithNodeFilters, error) {
+	var result []v1alpha5.PortWithNodeFilters
+	for _, mapping := range mappings {
+		if mapping.ContainerPort <= 0 {
+			return nil, fmt.Errorf("container port must be set for port mapping (host port: %d)", mapping.HostPort)
+		}
+
+		hostPort := mapping.HostPort
+		if hostPort == 0 {
+			var err error
+			hostPort, err = freeport.GetFreePort()
+			if err != nil {
+				return nil, fmt.Errorf("could not find free port for container port %d: %w", mapping.ContainerPort, err)
+			}
+		}
+
+		nodeFilters := mapping.NodeFilters
+		if len(nodeFilters) == 0 {
+			nodeFilters = []string{loadBalancerNodeFilter}
+		}
+
+		result = append(result, v1alpha5.PortWithNodeFilters{
+			Port:        fmt.Sprintf("%d:%d", hostPort, mapping.ContainerPort),
+			NodeFilters: nodeFilters,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_createPortConfig(t *testing.T) {
+	t.Run("should map ports", func(t *testing.T) {
+		mappings := []PortMapping{
+			{ContainerPort: 80, HostPort: 8080},
+			{ContainerPort: 30080, HostPort: 30080, NodeFilters: []string{"agent:0:direct"}},
+		}
+
+		actual, err := createPortConfig(mappings)
+
+		require.NoError(t, err)
+		expected := []v1alpha5.PortWithNodeFilters{
+			{Port: "8080:80", NodeFilters: []string{"loadbalancer"}},
+			{Port: "30080:30080", NodeFilters: []string{"agent:0:direct"}},
+		}
+		assert.Equal(t, expected, actual)
+	})
+	t.Run("should choose random host port", func(t *testing.T) {
+		actual, err := createPortConfig([]PortMapping{{ContainerPort: 80}})
+
+		require.NoError(t, err)
+		require.Len(t, actual, 1)
+		assert.Regexp(t, "^[0-9]+:80$", actual[0].Port)
+		assert.NotEqual(t, "0:80", actual[0].Port)
+	})
+	t.Run("should fail on missing container port", func(t *testing.T) {
+		_, err := createPortConfig([]PortMapping{{HostPort: 8080}})
+
+		require.Error(t, err)
+	})
+}
+
+func TestK3dCluster_HostURL(t *testing.T) {
+	lbNode := &k3dTypes.Node{
+		Role:  k3dTypes.LoadBalancerRole,
+		Ports: nat.PortMap{"80/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "43567"}}},
+	}
+	sut := &K3dCluster{ClusterName: "tc-abcdef-12345678", clusterConfig: &v1alpha5.ClusterConfig{
+		Cluster: k3dTypes.Cluster{Nodes: []*k3dTypes.Node{{Role: k3dTypes.ServerRole}, lbNode}},
+	}}
+
+	t.Run("should return URL of published port", func(t *testing.T) {
+		actual, err := sut.HostURL(80)
+
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:43567", actual)
+	})
+	t.Run("should fail on unpublished port", func(t *testing.T) {
+		_, err := sut.HostURL(443)
+
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,264 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/k3d-io/k3d/v5/pkg/client"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	dockerRuntime "github.com/k3d-io/k3d/v5/pkg/runtimes/docker"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+)
+
+// Runtime labels which are added to every cluster container and the image
+// volume so that leaked clusters can be traced back to their test.
+const (
+	labelPrefix       = "testclusters-go."
+	labelWorkDir      = labelPrefix + "workdir"
+	labelTest         = labelPrefix + "test"
+	labelPID          = labelPrefix + "pid"
+	labelHost         = labelPrefix + "host"
+	labelCreationTime = labelPrefix + "created"
+)
+
+// clusterLabels returns the runtime labels which identify the owner of a new cluster.
+func clusterLabels(testName string) map[string]string {
+	workDir, _ := os.Getwd()
+	hostname, _ := os.Hostname()
+
+	return map[string]string{
+		labelWorkDir:      workDir,
+		labelTest:         testName,
+		labelPID:          strconv.Itoa(os.Getpid()),
+		labelHost:         hostname,
+		labelCreationTime: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// ReapStale deletes all testclusters-go clusters which are older than the given
+// duration and whose owning test process on this host is gone. This removes
+// clusters that were leaked by killed test runs. Networks and volumes are
+// removed along with their cluster. Image volumes and networks of
+// testclusters-go clusters whose nodes are gone, f. i. because the test process
+// was killed while creating or deleting the cluster, are removed as well.
+// ReapStale returns the names of the deleted clusters.
+func ReapStale(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	err := prepareContainerRuntime(Opts{})
+	if err != nil {
+		return nil, fmt.Errorf("container runtime preflight failed: %w", err)
+	}
+
+	containerRuntime := runtimes.SelectedRuntime
+
+	clusters, err := client.ClusterList(ctx, containerRuntime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	var reaped []string
+	var errs []error
+	remaining := map[string]bool{}
+	for _, cluster := range clusters {
+		labels := clusterRuntimeLabels(cluster)
+		kept := false
+		if _, keepOnFailure := labels[labelKeepOnFailure]; keepOnFailure {
+			kept, err = wasKept(ctx, containerRuntime, cluster.Name)
+			if err != nil {
+				remaining[cluster.Name] = true
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if !isStale(labels, kept, hostname, olderThan, time.Now()) {
+			remaining[cluster.Name] = true
+			continue
+		}
+
+		l.Log().Infof("testcluster-go: Reaping stale cluster %s of test %s (pid %s)", cluster.Name, labels[labelTest], labels[labelPID])
+		err = client.ClusterDelete(ctx, containerRuntime, cluster, k3dTypes.ClusterDeleteOpts{})
+		if err != nil {
+			remaining[cluster.Name] = true
+			errs = append(errs, fmt.Errorf("failed to delete cluster %s: %w", cluster.Name, err))
+			continue
+		}
+		reaped = append(reaped, cluster.Name)
+	}
+
+	errs = append(errs, reapOrphanedVolumes(ctx, hostname, remaining, olderThan))
+
+	return reaped, errors.Join(errs...)
+}
+
+// labelNetwork records the network of the cluster on its image volume because
+// k3d does not label networks.
+const labelNetwork = labelPrefix + "network"
+
+// createImageVolume creates the image volume of the cluster before k3d does.
+// k3d adopts the existing volume, so this lets the volume carry the runtime
+// labels of the cluster. The volume outlives the cluster nodes if a test run is
+// killed during the creation or deletion of the cluster. Then, its labels tell
+// which volume and network belong to a gone testclusters-go process.
+func createImageVolume(ctx context.Context, containerRuntime runtimes.Runtime, clusterConfig *v1alpha5.ClusterConfig) error {
+	cluster := clusterConfig.Cluster
+	labels := map[string]string{k3dTypes.LabelClusterName: cluster.Name}
+	for key, value := range clusterConfig.ClusterCreateOpts.GlobalLabels {
+		labels[key] = value
+	}
+	if !cluster.Network.External {
+		labels[labelNetwork] = cluster.Network.Name
+	}
+
+	err := containerRuntime.CreateVolume(ctx, imageVolumeName(cluster.Name), labels)
+	if err != nil {
+		return fmt.Errorf("failed to create image volume of cluster %s: %w", cluster.Name, err)
+	}
+
+	return nil
+}
+
+// imageVolumeName returns the name which k3d uses for the image volume of a cluster.
+func imageVolumeName(clusterName string) string {
+	return fmt.Sprintf("%s-%s-images", k3dTypes.DefaultObjectNamePrefix, clusterName)
+}
+
+// reapOrphanedVolumes deletes the image volumes and networks of testclusters-go
+// clusters whose nodes are gone and whose owning test process is gone as well.
+// Volumes and networks of other k3d clusters are left alone.
+func reapOrphanedVolumes(ctx context.Context, hostname string, clusters map[string]bool, olderThan time.Duration) error {
+	docker, err := dockerRuntime.GetDockerClient()
+	if err != nil {
+		return fmt.Errorf("failed to get docker client: %w", err)
+	}
+	defer docker.Close()
+
+	volumes, err := docker.VolumeList(ctx, volume.ListOptions{Filters: filters.NewArgs(filters.Arg("label", labelPID))})
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var errs []error
+	for _, vol := range volumes.Volumes {
+		if !isOrphaned(vol.Labels, clusters, hostname, olderThan, time.Now()) {
+			continue
+		}
+
+		if network := vol.Labels[labelNetwork]; network != "" {
+			errs = append(errs, removeOrphanedNetwork(ctx, docker, network))
+		}
+
+		l.Log().Infof("testcluster-go: Reaping orphaned volume %s of test %s (pid %s)", vol.Name, vol.Labels[labelTest], vol.Labels[labelPID])
+		err = docker.VolumeRemove(ctx, vol.Name, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete volume %s: %w", vol.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func removeOrphanedNetwork(ctx context.Context, docker dockerClient.APIClient, network string) error {
+	inspected, err := docker.NetworkInspect(ctx, network, types.NetworkInspectOptions{})
+	if errdefs.IsNotFound(err) {
+		// removed along with the cluster
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect network %s: %w", network, err)
+	}
+	if len(inspected.Containers) > 0 {
+		l.Log().Warnf("testcluster-go: Keeping orphaned network %s because containers are still connected to it", network)
+		return nil
+	}
+
+	l.Log().Infof("testcluster-go: Reaping orphaned network %s", network)
+	err = docker.NetworkRemove(ctx, network)
+	if err != nil {
+		return fmt.Errorf("failed to delete network %s: %w", network, err)
+	}
+
+	return nil
+}
+
+// isOrphaned checks whether the labels of an image volume belong to a
+// testclusters-go cluster which has no nodes anymore and whose owning test
+// process is gone.
+func isOrphaned(labels map[string]string, clusters map[string]bool, hostname string, olderThan time.Duration, now time.Time) bool {
+	cluster := labels[k3dTypes.LabelClusterName]
+	if cluster == "" || clusters[cluster] {
+		return false
+	}
+
+	// kept clusters have nodes
+	return isStale(labels, false, hostname, olderThan, now)
+}
+
+func clusterRuntimeLabels(cluster *k3dTypes.Cluster) map[string]string {
+	for _, node := range cluster.Nodes {
+		if _, ok := node.RuntimeLabels[labelPID]; ok {
+			return node.RuntimeLabels
+		}
+	}
+	return map[string]string{}
+}
+
+// isStale checks whether a cluster with the given labels was created by a
+// testclusters-go process on this host which is no longer running. kept tells
+// whether the cluster was kept after a failed test, see wasKept.
+func isStale(labels map[string]string, kept bool, hostname string, olderThan time.Duration, now time.Time) bool {
+	pid, err := strconv.Atoi(labels[labelPID])
+	if err != nil {
+		// not created by testclusters-go
+		return false
+	}
+
+	if _, reusable := labels[labelReuse]; reusable {
+		// reusable clusters outlive their test process on purpose
+		return false
+	}
+
+	if kept {
+		// clusters of failed tests are deleted by the developer after inspection
+		return false
+	}
+
+	if labels[labelHost] != hostname {
+		// the owning process cannot be checked on other hosts
+		return false
+	}
+
+	created, err := time.Parse(time.RFC3339, labels[labelCreationTime])
+	if err != nil || now.Sub(created) < olderThan {
+		return false
+	}
+
+	return !isProcessAlive(pid)
+}
+
+func isProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		// finding a process on Windows already requires the process to exist
+		return true
+	}
+
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_clusterLabels(t *testing.T) {
+	actual := clusterLabels("TestSomething")
+
+	assert.Equal(t, "TestSomething", actual[labelTest])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), actual[labelPID])
+	assert.NotEmpty(t, actual[labelWorkDir])
+	assert.NotEmpty(t, actual[labelCreationTime])
+}
+
+func Test_isStale(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour).Format(time.RFC3339)
+	const deadPID = "999999999"
+	ownPID := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		kept      bool
+		olderThan time.Duration
+		want      bool
+	}{
+		{"foreign cluster", map[string]string{}, false, 0, false},
+		{"owning process is gone", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created}, false, 10 * time.Minute, true},
+		{"owning process is running", map[string]string{labelPID: ownPID, labelHost: "host", labelCreationTime: created}, false, 10 * time.Minute, false},
+		{"cluster of other host", map[string]string{labelPID: deadPID, labelHost: "other", labelCreationTime: created}, false, 10 * time.Minute, false},
+		{"cluster too young", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created}, false, 2 * time.Hour, false},
+		{"reusable cluster", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created, labelReuse: "true"}, false, 10 * time.Minute, false},
+		{"cluster kept on failure", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created, labelKeepOnFailure: "true"}, true, 10 * time.Minute, false},
+		{"labelled cluster which was never kept", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created, labelKeepOnFailure: "true"}, false, 10 * time.Minute, true},
+		{"invalid creation time", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: "yesterday"}, false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isStale(tt.labels, tt.kept, "host", tt.olderThan, now))
+		})
+	}
+}
+
+func Test_wasKept(t *testing.T) {
+	containerRuntime := &volumeRecordingRuntime{volumes: map[string]map[string]string{}}
+	require.NoError(t, markKept(testCtx, containerRuntime, "kept-cluster"))
+
+	t.Run("should find marker of kept cluster", func(t *testing.T) {
+		kept, err := wasKept(testCtx, containerRuntime, "kept-cluster")
+
+		require.NoError(t, err)
+		assert.True(t, kept)
+	})
+	t.Run("should not find marker of labelled cluster which was never kept", func(t *testing.T) {
+		kept, err := wasKept(testCtx, containerRuntime, "leaked-cluster")
+
+		require.NoError(t, err)
+		assert.False(t, kept)
+	})
+}
+
+func Test_isOrphaned(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour).Format(time.RFC3339)
+	const deadPID = "999999999"
+	clusters := map[string]bool{"running": true}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"volume of gone cluster and process", map[string]string{k3dTypes.LabelClusterName: "gone", labelPID: deadPID, labelHost: "host", labelCreationTime: created}, true},
+		{"volume of existing cluster", map[string]string{k3dTypes.LabelClusterName: "running", labelPID: deadPID, labelHost: "host", labelCreationTime: created}, false},
+		{"volume of running process", map[string]string{k3dTypes.LabelClusterName: "gone", labelPID: strconv.Itoa(os.Getpid()), labelHost: "host", labelCreationTime: created}, false},
+		{"volume of other k3d cluster", map[string]string{k3dTypes.LabelClusterName: "gone"}, false},
+		{"volume without cluster", map[string]string{labelPID: deadPID, labelHost: "host", labelCreationTime: created}, false},
+		{"volume too young", map[string]string{k3dTypes.LabelClusterName: "gone", labelPID: deadPID, labelHost: "host", labelCreationTime: now.Format(time.RFC3339)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isOrphaned(tt.labels, clusters, "host", 10*time.Minute, now))
+		})
+	}
+}
+
+// volumeRecordingRuntime records created volumes and finds them by label. Other
+// runtime calls panic.
+type volumeRecordingRuntime struct {
+	runtimes.Runtime
+	volumes map[string]map[string]string
+}
+
+func (r *volumeRecordingRuntime) CreateVolume(_ context.Context, name string, labels map[string]string) error {
+	r.volumes[name] = labels
+	return nil
+}
+
+func (r *volumeRecordingRuntime) GetVolumesByLabel(_ context.Context, labels map[string]string) ([]string, error) {
+	var volumes []string
+	for name, volumeLabels := range r.volumes {
+		matches := true
+		for key, value := range labels {
+			matches = matches && volumeLabels[key] == value
+		}
+		if matches {
+			volumes = append(volumes, name)
+		}
+	}
+	return volumes, nil
+}
+
+func Test_createImageVolume(t *testing.T) {
+	newClusterConfig := func(network k3dTypes.ClusterNetwork) *v1alpha5.ClusterConfig {
+		clusterConfig := &v1alpha5.ClusterConfig{Cluster: k3dTypes.Cluster{Name: "tcg-7d476345", Network: network}}
+		clusterConfig.ClusterCreateOpts.GlobalLabels = clusterLabels("TestSomething")
+		return clusterConfig
+	}
+
+	t.Run("should label image volume with owner and network", func(t *testing.T) {
+		containerRuntime := &volumeRecordingRuntime{volumes: map[string]map[string]string{}}
+
+		err := createImageVolume(testCtx, containerRuntime, newClusterConfig(k3dTypes.ClusterNetwork{Name: "k3d-tcg-7d476345"}))
+
+		require.NoError(t, err)
+		labels := containerRuntime.volumes["k3d-tcg-7d476345-images"]
+		require.NotNil(t, labels)
+		assert.Equal(t, "tcg-7d476345", labels[k3dTypes.LabelClusterName])
+		assert.Equal(t, "TestSomething", labels[labelTest])
+		assert.Equal(t, strconv.Itoa(os.Getpid()), labels[labelPID])
+		assert.Equal(t, "k3d-tcg-7d476345", labels[labelNetwork])
+	})
+	t.Run("should not claim external network", func(t *testing.T) {
+		containerRuntime := &volumeRecordingRuntime{volumes: map[string]map[string]string{}}
+
+		err := createImageVolume(testCtx, containerRuntime, newClusterConfig(k3dTypes.ClusterNetwork{Name: "shared", External: true}))
+
+		require.NoError(t, err)
+		assert.NotContains(t, containerRuntime.volumes["k3d-tcg-7d476345-images"], labelNetwork)
+	})
+}
@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/phayes/freeport"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	registryHostAddress = "localhost"
+	// defaultRegistryProxyURL lets the default registry cache images of Docker Hub.
+	defaultRegistryProxyURL = "https://registry-1.docker.io"
+)
+
+// RegistryOpts configures the image registry which will be created along with
+// the cluster. The registry allows to push unpublished images-under-test from
+// the host so that they can be used in the cluster.
+type RegistryOpts struct {
+	// HostPort binds the registry to this port on the host.
+	// Defaults to a random free port so that parallel clusters do not collide.
+	HostPort int
+	// ProxyRemoteURL turns the registry into a pull-through cache for the given
+	// remote registry, f. i. "https://registry-1.docker.io".
+	// Defaults to the empty string which disables the proxy.
+	ProxyRemoteURL string
+	// ProxyUsername authenticates the registry against the remote registry.
+	ProxyUsername string
+	// ProxyPassword authenticates the registry against the remote registry.
+	ProxyPassword string
+	// Mirrors maps registry names to endpoints which the cluster nodes use to
+	// pull images instead, f. i. "docker.io": {"https://mirror.company.com"}.
+	Mirrors map[string][]string
+}
+
+// k3sRegistries reflects the k3s registries.yaml, see https://docs.k3s.io/installation/private-registry
+type k3sRegistries struct {
+	Mirrors map[string]k3sMirror `json:"mirrors"`
+}
+
+type k3sMirror struct {
+	Endpoints []string `json:"endpoint"`
+}
+
+// RegistryAddress returns the host address of the cluster's registry, f. i.
+// "localhost:12345". Images pushed to this address can be used in the cluster
+// with the same image reference, f. i. "localhost:12345/my-app:dev".
+func (c *K3dCluster) RegistryAddress() (string, error) {
+	registry := c.clusterConfig.ClusterCreateOpts.Registries.Create
+	if registry == nil {
+		return "", fmt.Errorf("cluster %s was created without registry (see Opts.DisableRegistry)", c.ClusterName)
+	}
+
+	return fmt.Sprintf("%s:%s", registryHostAddress, registry.ExposureOpts.Binding.HostPort), nil
+}
+
+// registryOfCluster restores the registry of an existing cluster from the
+// labels of its registry node because a cluster which was not created by this
+// process lacks the registry options, see attachK3dCluster.
+func registryOfCluster(cluster *k3dTypes.Cluster) *k3dTypes.Registry {
+	for _, node := range cluster.Nodes {
+		hostPort := node.RuntimeLabels[k3dTypes.LabelRegistryPortExternal]
+		if node.Role != k3dTypes.RegistryRole || hostPort == "" {
+			continue
+		}
+
+		return &k3dTypes.Registry{
+			ClusterRef: cluster.Name,
+			Host:       node.Name,
+			Image:      node.Image,
+			ExposureOpts: k3dTypes.ExposureOpts{
+				PortMapping: nat.PortMapping{
+					Binding: nat.PortBinding{
+						HostIP:   node.RuntimeLabels[k3dTypes.LabelRegistryHostIP],
+						HostPort: hostPort,
+					},
+				},
+				Host: node.RuntimeLabels[k3dTypes.LabelRegistryHost],
+			},
+		}
+	}
+
+	return nil
+}
+
+// registryOptsOf returns the options of the registry which will be created along
+// with the cluster, or nil if the cluster will be created without registry.
+func registryOptsOf(opts Opts) (*RegistryOpts, error) {
+	if opts.DisableRegistry {
+		if opts.Registry != nil {
+			return nil, fmt.Errorf("registry options must not be set if the registry is disabled")
+		}
+		return nil, nil
+	}
+
+	if opts.Registry == nil {
+		return &RegistryOpts{ProxyRemoteURL: defaultRegistryProxyURL}, nil
+	}
+	return opts.Registry, nil
+}
+
+func createRegistryConfig(clusterName string, opts *RegistryOpts) (v1alpha5.SimpleConfigRegistries, error) {
+	if opts == nil {
+		return v1alpha5.SimpleConfigRegistries{}, nil
+	}
+
+	hostPort := opts.HostPort
+	if hostPort == 0 {
+		var err error
+		hostPort, err = freeport.GetFreePort()
+		if err != nil {
+			return v1alpha5.SimpleConfigRegistries{}, fmt.Errorf("could not find free port for registry: %w", err)
+		}
+	}
+
+	registryName := fmt.Sprintf("%s-%s-registry", k3dTypes.DefaultObjectNamePrefix, clusterName)
+	registryConfig, err := createK3sRegistriesYaml(registryName, hostPort, opts.Mirrors)
+	if err != nil {
+		return v1alpha5.SimpleConfigRegistries{}, err
+	}
+
+	return v1alpha5.SimpleConfigRegistries{
+		Create: &v1alpha5.SimpleConfigRegistryCreateConfig{
+			Name:     registryName,
+			HostPort: strconv.Itoa(hostPort),
+			Proxy: k3dTypes.RegistryProxy{
+				RemoteURL: opts.ProxyRemoteURL,
+				Username:  opts.ProxyUsername,
+				Password:  opts.ProxyPassword,
+			},
+		},
+		Config: registryConfig,
+	}, nil
+}
+
+// createK3sRegistriesYaml returns a registries.yaml which contains the given
+// mirrors. Additionally, the registry's host address will be mirrored to the
+// registry so that pushed images can be used with the same reference.
+func createK3sRegistriesYaml(registryName string, hostPort int, mirrors map[string][]string) (string, error) {
+	registries := k3sRegistries{Mirrors: map[string]k3sMirror{}}
+
+	for name, endpoints := range mirrors {
+		if len(endpoints) == 0 {
+			return "", fmt.Errorf("registry mirror %s must contain at least one endpoint", name)
+		}
+		registries.Mirrors[name] = k3sMirror{Endpoints: endpoints}
+	}
+
+	hostAddress := fmt.Sprintf("%s:%d", registryHostAddress, hostPort)
+	registries.Mirrors[hostAddress] = k3sMirror{
+		Endpoints: []string{fmt.Sprintf("http://%s:%s", registryName, k3dTypes.DefaultRegistryPort)},
+	}
+
+	registriesYaml, err := yaml.Marshal(registries)
+	if err != nil {
+		return "", fmt.Errorf("failed to create registries.yaml: %w", err)
+	}
+
+	return string(registriesYaml), nil
+}
@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_registryOptsOf(t *testing.T) {
+	t.Run("should create registry by default", func(t *testing.T) {
+		actual, err := registryOptsOf(Opts{})
+
+		require.NoError(t, err)
+		assert.Equal(t, &RegistryOpts{ProxyRemoteURL: "https://registry-1.docker.io"}, actual)
+	})
+	t.Run("should use configured registry", func(t *testing.T) {
+		opts := &RegistryOpts{HostPort: 5001}
+
+		actual, err := registryOptsOf(Opts{Registry: opts})
+
+		require.NoError(t, err)
+		assert.Same(t, opts, actual)
+	})
+	t.Run("should create no registry if disabled", func(t *testing.T) {
+		actual, err := registryOptsOf(Opts{DisableRegistry: true})
+
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+	t.Run("should fail on disabled but configured registry", func(t *testing.T) {
+		_, err := registryOptsOf(Opts{DisableRegistry: true, Registry: &RegistryOpts{}})
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "must not be set if the registry is disabled")
+	})
+}
+
+func Test_createRegistryConfig(t *testing.T) {
+	t.Run("should create no registry without options", func(t *testing.T) {
+		actual, err := createRegistryConfig("tc-abcdef-12345678", nil)
+
+		require.NoError(t, err)
+		assert.Nil(t, actual.Create)
+		assert.Empty(t, actual.Config)
+	})
+	t.Run("should create registry with random port", func(t *testing.T) {
+		actual, err := createRegistryConfig("tc-abcdef-12345678", &RegistryOpts{})
+
+		require.NoError(t, err)
+		require.NotNil(t, actual.Create)
+		assert.Equal(t, "k3d-tc-abcdef-12345678-registry", actual.Create.Name)
+		assert.NotEmpty(t, actual.Create.HostPort)
+		assert.Empty(t, actual.Create.Proxy.RemoteURL)
+	})
+	t.Run("should create registry with configured options", func(t *testing.T) {
+		opts := &RegistryOpts{
+			HostPort:       5001,
+			ProxyRemoteURL: "https://registry-1.docker.io",
+			Mirrors:        map[string][]string{"docker.io": {"https://mirror.company.com"}},
+		}
+
+		actual, err := createRegistryConfig("tc-abcdef-12345678", opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, "5001", actual.Create.HostPort)
+		assert.Equal(t, "https://registry-1.docker.io", actual.Create.Proxy.RemoteURL)
+		expectedYaml := `mirrors:
+  docker.io:
+    endpoint:
+    - https://mirror.company.com
+  localhost:5001:
+    endpoint:
+    - http://k3d-tc-abcdef-12345678-registry:5000
+`
+		assert.Equal(t, expectedYaml, actual.Config)
+	})
+	t.Run("should fail on mirror without endpoints", func(t *testing.T) {
+		_, err := createRegistryConfig("tc-abcdef-12345678", &RegistryOpts{Mirrors: map[string][]string{"docker.io": nil}})
+
+		require.Error(t, err)
+	})
+}
+
+func Test_registryOfCluster(t *testing.T) {
+	t.Run("should restore registry address of existing cluster", func(t *testing.T) {
+		existing := &k3dTypes.Cluster{
+			Name: "reuse-12345678",
+			Nodes: []*k3dTypes.Node{
+				{Name: "k3d-reuse-12345678-server-0", Role: k3dTypes.ServerRole},
+				{Name: "k3d-reuse-12345678-registry", Role: k3dTypes.RegistryRole, RuntimeLabels: map[string]string{k3dTypes.LabelRegistryPortExternal: "5001"}},
+			},
+		}
+		cl := &K3dCluster{ClusterName: existing.Name, clusterConfig: &v1alpha5.ClusterConfig{Cluster: *existing}}
+		cl.clusterConfig.ClusterCreateOpts.Registries.Create = registryOfCluster(existing)
+
+		actual, err := cl.RegistryAddress()
+
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:5001", actual)
+	})
+	t.Run("should find no registry", func(t *testing.T) {
+		existing := &k3dTypes.Cluster{Nodes: []*k3dTypes.Node{{Role: k3dTypes.ServerRole}}}
+
+		assert.Nil(t, registryOfCluster(existing))
+	})
+}
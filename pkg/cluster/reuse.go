@@ -0,0 +1,182 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k3d-io/k3d/v5/pkg/client"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+)
+
+// ReuseEnvVar enables Opts.Reuse for all clusters of a test run if set to true,
+// f. i. TESTCLUSTERS_REUSE=true go test ./...
+const ReuseEnvVar = "TESTCLUSTERS_REUSE"
+
+// labelReuse marks clusters that outlive their test process on purpose so that
+// they will not be reaped.
+const labelReuse = labelPrefix + "reuse"
+
+const (
+	reusePrefix       = "reuse"
+	reuseStartTimeout = 2 * time.Minute
+)
+
+// reuseMutex prevents tests of the same package from creating the same reusable
+// cluster in parallel.
+var reuseMutex sync.Mutex
+
+func reuseEnabled(opts Opts) bool {
+	if opts.Reuse {
+		return true
+	}
+
+	enabled, _ := strconv.ParseBool(os.Getenv(ReuseEnvVar))
+	return enabled
+}
+
+// reuseClusterName returns a stable cluster name so that following test runs of
+// the same package find the cluster again.
+func reuseClusterName(prefix string, workDir string) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(workDir))
+	hash := hex.EncodeToString(h.Sum(nil))[0:8]
+
+	if prefix == "" {
+		prefix = reusePrefix
+	}
+
+	clusterNamePrefix, err := validateClusterNamePrefix(prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid cluster name prefix found: %w", err)
+	}
+
+	name := clusterNamePrefix + "-" + hash
+	err = client.CheckName(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid reusable cluster name (consider a shorter cluster name prefix): %w", err)
+	}
+
+	return name, nil
+}
+
+// reuseOrCreateK3dCluster hands out an existing healthy cluster with a stable
+// name or creates it if there is none.
+func reuseOrCreateK3dCluster(ctx context.Context, opts Opts) (*K3dCluster, error) {
+	err := prepareContainerRuntime(opts)
+	if err != nil {
+		return nil, fmt.Errorf("container runtime preflight failed: %w", err)
+	}
+
+	workDir, _ := os.Getwd()
+	clusterName, err := reuseClusterName(opts.ClusterNamePrefix, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reuseMutex.Lock()
+	defer reuseMutex.Unlock()
+
+	cluster, err := attachK3dCluster(ctx, clusterName)
+	if err == nil {
+		l.Log().Infof("testcluster-go: Reusing cluster %s", clusterName)
+		return cluster, nil
+	}
+	if cluster != nil {
+		l.Log().Warnf("testcluster-go: Replacing unusable cluster %s: %s", clusterName, err.Error())
+		err = cluster.Terminate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete unusable cluster %s: %w", clusterName, err)
+		}
+	}
+
+	l.Log().Infof("testcluster-go: Creating reusable cluster %s", clusterName)
+	opts.reusable = true
+	cluster, err = createK3dCluster(ctx, clusterName, opts)
+	if err != nil {
+		return nil, err
+	}
+	cluster.reused = true
+
+	return cluster, nil
+}
+
+// attachK3dCluster connects to an existing cluster, starts it if necessary and
+// wipes test namespaces of previous test runs. An error without cluster is
+// returned if there is no such cluster. An error with cluster is returned if the
+// cluster exists but cannot be used.
+func attachK3dCluster(ctx context.Context, clusterName string) (*K3dCluster, error) {
+	containerRuntime := runtimes.SelectedRuntime
+
+	existing, err := client.ClusterGet(ctx, containerRuntime, &k3dTypes.Cluster{Name: clusterName})
+	if err != nil {
+		if errors.Is(err, client.ClusterGetNoNodesFoundError) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to look up cluster %s: %w", clusterName, err)
+	}
+
+	cl := &K3dCluster{
+		containerRuntime:    containerRuntime,
+		clusterConfig:       &v1alpha5.ClusterConfig{Cluster: *existing},
+		ClusterName:         clusterName,
+		AdminServiceAccount: "sa-" + globalGalacticClusterAdminSuffix,
+		reused:              true,
+	}
+	// the registry options only exist in the process which created the cluster
+	cl.clusterConfig.ClusterCreateOpts.Registries.Create = registryOfCluster(existing)
+
+	if !isClusterRunning(existing) {
+		startOpts, err := client.GetClusterStartOptsFromLabels(existing)
+		if err != nil {
+			return cl, err
+		}
+		startOpts.WaitForServer = true
+		startOpts.Timeout = reuseStartTimeout
+
+		err = client.ClusterStart(ctx, containerRuntime, &cl.clusterConfig.Cluster, startOpts)
+		if err != nil {
+			return cl, fmt.Errorf("failed to start cluster: %w", err)
+		}
+	}
+
+	cl.kubeConfig, err = client.KubeconfigGet(ctx, containerRuntime, &cl.clusterConfig.Cluster)
+	if err != nil {
+		return cl, fmt.Errorf("failed to get kube config: %w", err)
+	}
+
+	err = initializeClientSet(cl)
+	if err != nil {
+		return cl, fmt.Errorf("failed to initialize clientset: %w", err)
+	}
+
+	err = cl.checkNodeHealth(ctx, NodeHealthCheckOpts{ExpectedNodes: k3sNodeCount(cl.clusterConfig)})
+	if err != nil {
+		return cl, fmt.Errorf("failed to check node health: %w", err)
+	}
+
+	err = wipeTestNamespaces(ctx, cl.clientSet)
+	if err != nil {
+		return cl, err
+	}
+
+	return cl, nil
+}
+
+func isClusterRunning(cluster *k3dTypes.Cluster) bool {
+	for _, node := range cluster.Nodes {
+		if !node.State.Running {
+			return false
+		}
+	}
+	return true
+}
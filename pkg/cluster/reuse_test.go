@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reuseEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Opts
+		envVar string
+		want   bool
+	}{
+		{"disabled by default", Opts{}, "", false},
+		{"enabled by option", Opts{Reuse: true}, "", true},
+		{"enabled by environment", Opts{}, "true", true},
+		{"ignores invalid environment value", Opts{}, "yes please", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ReuseEnvVar, tt.envVar)
+
+			assert.Equal(t, tt.want, reuseEnabled(tt.opts))
+		})
+	}
+}
+
+func Test_reuseClusterName(t *testing.T) {
+	t.Run("should be stable for the same package", func(t *testing.T) {
+		first, err := reuseClusterName("", "/src/my/package")
+		require.NoError(t, err)
+		second, err := reuseClusterName("", "/src/my/package")
+		require.NoError(t, err)
+
+		assert.Regexp(t, "^tc-reuse-[a-f0-9]{8}$", first)
+		assert.Equal(t, first, second)
+	})
+	t.Run("should differ between packages", func(t *testing.T) {
+		first, err := reuseClusterName("", "/src/my/package")
+		require.NoError(t, err)
+		second, err := reuseClusterName("", "/src/my/other-package")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first, second)
+	})
+	t.Run("should use prefix", func(t *testing.T) {
+		name, err := reuseClusterName("mytest", "/src/my/package")
+
+		require.NoError(t, err)
+		assert.Regexp(t, "^tc-mytest-[a-f0-9]{8}$", name)
+	})
+	t.Run("should fail on too long names", func(t *testing.T) {
+		_, err := reuseClusterName("a-quite-long-cluster-prefix", "/src/my/package")
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "shorter cluster name prefix")
+	})
+}
@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+)
+
+// ContainerRuntime selects the container engine which runs the cluster nodes, see Opts.Runtime.
+type ContainerRuntime string
+
+const (
+	// RuntimeDocker uses the Docker daemon.
+	RuntimeDocker ContainerRuntime = "docker"
+	// RuntimePodman uses Podman's Docker-compatible API socket, rootless or rootful.
+	RuntimePodman ContainerRuntime = "podman"
+)
+
+const (
+	// k3d talks to every runtime through the Docker API which is configured by these variables.
+	dockerHostEnvVar = "DOCKER_HOST"
+	dockerSockEnvVar = "DOCKER_SOCK"
+	xdgRuntimeDirVar = "XDG_RUNTIME_DIR"
+
+	unixSocketScheme     = "unix://"
+	defaultDockerSocket  = "/var/run/docker.sock"
+	rootfulPodmanSocket  = "/run/podman/podman.sock"
+	rootlessPodmanSocket = "podman/podman.sock"
+	socketDialTimeout    = 2 * time.Second
+
+	defaultCgroupRoot     = "/sys/fs/cgroup"
+	cgroupControllersFile = "cgroup.controllers"
+)
+
+// requiredCgroupControllers must be delegated to rootless users so that k3s can run.
+var requiredCgroupControllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+var (
+	runtimeMutex        sync.Mutex
+	preparedRuntimeHost *runtimeHost
+)
+
+// runtimeHost describes how to reach the selected container runtime.
+type runtimeHost struct {
+	runtime ContainerRuntime
+	// dockerHost contains the DOCKER_HOST value, f. i. "unix:///run/user/1000/podman/podman.sock".
+	dockerHost string
+	// socket contains the path of local unix sockets.
+	socket   string
+	rootless bool
+}
+
+// runtimeEnvironment abstracts the host so that the runtime detection can be tested.
+type runtimeEnvironment struct {
+	getenv              func(string) string
+	uid                 int
+	cgroupRoot          string
+	dockerSocket        string
+	rootfulPodmanSocket string
+}
+
+func defaultRuntimeEnvironment() runtimeEnvironment {
+	return runtimeEnvironment{
+		getenv:              os.Getenv,
+		uid:                 os.Getuid(),
+		cgroupRoot:          defaultCgroupRoot,
+		dockerSocket:        defaultDockerSocket,
+		rootfulPodmanSocket: rootfulPodmanSocket,
+	}
+}
+
+// prepareContainerRuntime detects the container runtime, checks whether a
+// cluster can be created with it and points k3d at it. All clusters of a test
+// process share the same runtime.
+func prepareContainerRuntime(opts Opts) error {
+	runtimeMutex.Lock()
+	defer runtimeMutex.Unlock()
+
+	if preparedRuntimeHost != nil {
+		if opts.Runtime != "" && opts.Runtime != preparedRuntimeHost.runtime {
+			return fmt.Errorf("container runtime %s was requested but this test process already uses %s", opts.Runtime, preparedRuntimeHost.runtime)
+		}
+		return nil
+	}
+
+	env := defaultRuntimeEnvironment()
+	host, err := env.detect(opts.Runtime)
+	if err != nil {
+		return err
+	}
+
+	err = env.preflight(host)
+	if err != nil {
+		return err
+	}
+
+	if env.getenv(dockerHostEnvVar) == "" && host.socket != env.dockerSocket {
+		_ = os.Setenv(dockerHostEnvVar, host.dockerHost)
+	}
+	if env.getenv(dockerSockEnvVar) == "" && host.socket != "" {
+		// k3d mounts this socket into its tools container, f. i. to import images
+		_ = os.Setenv(dockerSockEnvVar, host.socket)
+	}
+
+	if host.runtime == RuntimePodman && !opts.DisableRegistry {
+		l.Log().Warn("testcluster-go: Creating registries along with the cluster is not guaranteed to work with Podman (see Opts.DisableRegistry)")
+	}
+
+	l.Log().Debugf("testcluster-go: using container runtime %s at %s (rootless: %t)", host.runtime, host.dockerHost, host.rootless)
+	preparedRuntimeHost = &host
+	return nil
+}
+
+// detect finds the socket of the requested runtime. Without a requested
+// runtime, DOCKER_HOST takes precedence over the Docker socket which takes
+// precedence over the Podman sockets.
+func (e runtimeEnvironment) detect(requested ContainerRuntime) (runtimeHost, error) {
+	if requested != "" && requested != RuntimeDocker && requested != RuntimePodman {
+		return runtimeHost{}, fmt.Errorf("unsupported container runtime %s (supported: %s, %s)", requested, RuntimeDocker, RuntimePodman)
+	}
+
+	if dockerHost := e.getenv(dockerHostEnvVar); dockerHost != "" {
+		runtime := requested
+		if runtime == "" {
+			runtime = RuntimeDocker
+			if strings.Contains(dockerHost, string(RuntimePodman)) {
+				runtime = RuntimePodman
+			}
+		}
+		return e.newRuntimeHost(runtime, dockerHost), nil
+	}
+
+	var candidates []string
+	if requested != RuntimePodman {
+		candidates = append(candidates, e.dockerSocket)
+	}
+	if requested != RuntimeDocker {
+		candidates = append(candidates, e.rootlessPodmanSocket(), e.rootfulPodmanSocket)
+	}
+
+	for _, socket := range candidates {
+		if _, err := os.Stat(socket); err != nil {
+			continue
+		}
+
+		runtime := RuntimeDocker
+		if socket != e.dockerSocket {
+			runtime = RuntimePodman
+		}
+		return e.newRuntimeHost(runtime, unixSocketScheme+socket), nil
+	}
+
+	return runtimeHost{}, fmt.Errorf("no container runtime socket found (tried %s): start Docker, "+
+		"start the Podman socket with 'systemctl --user enable --now podman.socket' or set %s",
+		strings.Join(candidates, ", "), dockerHostEnvVar)
+}
+
+func (e runtimeEnvironment) newRuntimeHost(runtime ContainerRuntime, dockerHost string) runtimeHost {
+	host := runtimeHost{runtime: runtime, dockerHost: dockerHost}
+	if strings.HasPrefix(dockerHost, unixSocketScheme) {
+		host.socket = strings.TrimPrefix(dockerHost, unixSocketScheme)
+		host.rootless = runtime == RuntimePodman && host.socket != e.rootfulPodmanSocket
+	}
+
+	return host
+}
+
+func (e runtimeEnvironment) rootlessPodmanSocket() string {
+	runtimeDir := e.getenv(xdgRuntimeDirVar)
+	if runtimeDir == "" {
+		runtimeDir = fmt.Sprintf("/run/user/%d", e.uid)
+	}
+
+	return filepath.Join(runtimeDir, rootlessPodmanSocket)
+}
+
+// preflight explains common misconfigurations before the cluster creation fails
+// with an obscure error.
+func (e runtimeEnvironment) preflight(host runtimeHost) error {
+	if host.socket != "" {
+		err := checkSocket(host.socket)
+		if err != nil {
+			return err
+		}
+	}
+
+	if host.rootless {
+		return e.checkCgroupDelegation()
+	}
+
+	return nil
+}
+
+func checkSocket(socket string) error {
+	conn, err := net.DialTimeout("unix", socket, socketDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("permission denied for container runtime socket %s: add your user to the socket's group "+
+			"(f. i. 'docker') or use rootless Podman: %w", socket, err)
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("container runtime socket %s does not exist: %w", socket, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("container runtime does not listen on socket %s (is the daemon or podman.socket running?): %w", socket, err)
+	default:
+		return fmt.Errorf("failed to connect to container runtime socket %s: %w", socket, err)
+	}
+}
+
+// checkCgroupDelegation checks whether the cgroup controllers which k3s needs
+// are delegated to the current user, see https://rootlesscontaine.rs/getting-started/common/cgroup2/
+func (e runtimeEnvironment) checkCgroupDelegation() error {
+	if _, err := os.Stat(filepath.Join(e.cgroupRoot, cgroupControllersFile)); err != nil {
+		return fmt.Errorf("rootless Podman requires cgroup v2 but %s looks like cgroup v1", e.cgroupRoot)
+	}
+
+	userService := fmt.Sprintf("user.slice/user-%d.slice/user@%d.service", e.uid, e.uid)
+	content, err := os.ReadFile(filepath.Join(e.cgroupRoot, userService, cgroupControllersFile))
+	if err != nil {
+		// without systemd the delegation cannot be checked
+		l.Log().Debugf("testcluster-go: skipping cgroup delegation check: %s", err.Error())
+		return nil
+	}
+
+	delegated := map[string]bool{}
+	for _, controller := range strings.Fields(string(content)) {
+		delegated[controller] = true
+	}
+
+	var missing []string
+	for _, controller := range requiredCgroupControllers {
+		if !delegated[controller] {
+			missing = append(missing, controller)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("cgroup controllers %s are not delegated to user %d which rootless Podman needs to run k3s: "+
+			"add 'Delegate=%s' to /etc/systemd/system/user@.service.d/delegate.conf and run 'systemctl daemon-reload'",
+			strings.Join(missing, ", "), e.uid, strings.Join(requiredCgroupControllers, " "))
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRuntimeEnvironment points all runtime sockets into a temporary
+// directory. Unix socket paths are limited in length, so t.TempDir is too long.
+func newTestRuntimeEnvironment(t *testing.T, env map[string]string) (runtimeEnvironment, string) {
+	dir, err := os.MkdirTemp("", "tc")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	if _, ok := env[xdgRuntimeDirVar]; !ok {
+		env[xdgRuntimeDirVar] = filepath.Join(dir, "user")
+	}
+
+	return runtimeEnvironment{
+		getenv:              func(key string) string { return env[key] },
+		uid:                 1000,
+		cgroupRoot:          filepath.Join(dir, "cgroup"),
+		dockerSocket:        filepath.Join(dir, "docker.sock"),
+		rootfulPodmanSocket: filepath.Join(dir, "podman.sock"),
+	}, dir
+}
+
+func listenOnSocket(t *testing.T, socket string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(socket), 0700))
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+}
+
+func Test_runtimeEnvironment_detect(t *testing.T) {
+	t.Run("should prefer DOCKER_HOST", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{dockerHostEnvVar: "unix:///run/user/1000/podman/podman.sock"})
+
+		host, err := env.detect("")
+
+		require.NoError(t, err)
+		assert.Equal(t, runtimeHost{runtime: RuntimePodman, dockerHost: "unix:///run/user/1000/podman/podman.sock", socket: "/run/user/1000/podman/podman.sock", rootless: true}, host)
+	})
+	t.Run("should accept remote DOCKER_HOST", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{dockerHostEnvVar: "tcp://docker:2375"})
+
+		host, err := env.detect("")
+
+		require.NoError(t, err)
+		assert.Equal(t, runtimeHost{runtime: RuntimeDocker, dockerHost: "tcp://docker:2375"}, host)
+	})
+	t.Run("should prefer docker socket", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		listenOnSocket(t, env.dockerSocket)
+		listenOnSocket(t, env.rootfulPodmanSocket)
+
+		host, err := env.detect("")
+
+		require.NoError(t, err)
+		assert.Equal(t, RuntimeDocker, host.runtime)
+		assert.False(t, host.rootless)
+	})
+	t.Run("should find rootless podman socket", func(t *testing.T) {
+		env, dir := newTestRuntimeEnvironment(t, map[string]string{})
+		listenOnSocket(t, env.dockerSocket)
+		listenOnSocket(t, filepath.Join(dir, "user", "podman", "podman.sock"))
+
+		host, err := env.detect(RuntimePodman)
+
+		require.NoError(t, err)
+		assert.Equal(t, RuntimePodman, host.runtime)
+		assert.Equal(t, "unix://"+filepath.Join(dir, "user", "podman", "podman.sock"), host.dockerHost)
+		assert.True(t, host.rootless)
+	})
+	t.Run("should find rootful podman socket", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		listenOnSocket(t, env.rootfulPodmanSocket)
+
+		host, err := env.detect("")
+
+		require.NoError(t, err)
+		assert.Equal(t, RuntimePodman, host.runtime)
+		assert.False(t, host.rootless)
+	})
+	t.Run("should fail without socket", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+
+		_, err := env.detect(RuntimeDocker)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "no container runtime socket found")
+	})
+	t.Run("should fail on unsupported runtime", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+
+		_, err := env.detect("containerd")
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "unsupported container runtime containerd")
+	})
+}
+
+func Test_checkSocket(t *testing.T) {
+	t.Run("should accept listening socket", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		listenOnSocket(t, env.dockerSocket)
+
+		assert.NoError(t, checkSocket(env.dockerSocket))
+	})
+	t.Run("should explain missing socket", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+
+		err := checkSocket(env.dockerSocket)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "does not exist")
+	})
+	t.Run("should explain socket without listener", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		listener, err := net.Listen("unix", env.dockerSocket)
+		require.NoError(t, err)
+		listener.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, listener.Close())
+
+		err = checkSocket(env.dockerSocket)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "does not listen")
+	})
+}
+
+func Test_runtimeEnvironment_checkCgroupDelegation(t *testing.T) {
+	userService := filepath.Join("user.slice", "user-1000.slice", "user@1000.service")
+	writeControllers := func(t *testing.T, dir, controllers string) {
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, cgroupControllersFile), []byte(controllers), 0600))
+	}
+
+	t.Run("should accept delegated controllers", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		writeControllers(t, env.cgroupRoot, "cpuset cpu io memory hugetlb pids rdma misc")
+		writeControllers(t, filepath.Join(env.cgroupRoot, userService), "cpuset cpu io memory pids")
+
+		assert.NoError(t, env.checkCgroupDelegation())
+	})
+	t.Run("should explain missing delegation", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+		writeControllers(t, env.cgroupRoot, "cpuset cpu io memory hugetlb pids rdma misc")
+		writeControllers(t, filepath.Join(env.cgroupRoot, userService), "memory pids")
+
+		err := env.checkCgroupDelegation()
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "cgroup controllers cpu, cpuset, io are not delegated to user 1000")
+	})
+	t.Run("should fail on cgroup v1", func(t *testing.T) {
+		env, _ := newTestRuntimeEnvironment(t, map[string]string{})
+
+		err := env.checkCgroupDelegation()
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "requires cgroup v2")
+	})
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+)
+
+const sharedClusterTestName = "TestMain"
+
+// SharedK3dCluster wraps a single K3dCluster that lives as long as all tests of
+// a test package. This saves the cluster start-up time for every single test.
+//
+// A SharedK3dCluster is usually created in TestMain:
+//
+//	var sharedCluster *cluster.SharedK3dCluster
+//
+//	func TestMain(m *testing.M) {
+//		var err error
+//		sharedCluster, err = cluster.NewSharedK3dCluster(m, cluster.Opts{})
+//		if err != nil {
+//			fmt.Println(err)
+//			os.Exit(1)
+//		}
+//		os.Exit(sharedCluster.Run())
+//	}
+type SharedK3dCluster struct {
+	*K3dCluster
+	m testRunner
+}
+
+// testRunner runs all tests of a package, f. i. testing.M.
+type testRunner interface {
+	Run() int
+}
+
+// NewSharedK3dCluster creates a new cluster that will be shared among all tests
+// run by the given testing.M. The cluster will be terminated once Run returns.
+func NewSharedK3dCluster(m *testing.M, opts Opts) (*SharedK3dCluster, error) {
+	l.Log().Info("testcluster-go: Creating shared cluster")
+	ctx := context.Background()
+
+	opts.testName = sharedClusterTestName
+	cluster, err := CreateK3dCluster(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shared cluster: %w", err)
+	}
+
+	return &SharedK3dCluster{K3dCluster: cluster, m: m}, nil
+}
+
+// Run runs all tests of the package and terminates the shared cluster
+// afterwards. It returns the exit code that should be passed to os.Exit.
+func (s *SharedK3dCluster) Run() int {
+	exitCode := s.m.Run()
+
+	l.Log().Debug("testcluster-go: Terminating shared cluster after all tests ran")
+	err := s.Terminate(context.Background())
+	if err != nil {
+		l.Log().Errorf("testcluster-go: Shared cluster termination failed (you may want to clean-up the container landscape): %s", err.Error())
+		if exitCode == 0 {
+			exitCode = 1
+		}
+		return exitCode
+	}
+	l.Log().Info("testcluster-go: Shared cluster was successfully terminated")
+
+	return exitCode
+}
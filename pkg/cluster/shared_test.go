@@ -0,0 +1,95 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTestRunner pretends to run the tests of a package and records whether
+// the shared cluster was still alive.
+type fakeTestRunner struct {
+	exitCode   int
+	runtime    *nodeDeletingRuntime
+	ran        bool
+	aliveOnRun bool
+}
+
+func (r *fakeTestRunner) Run() int {
+	r.ran = true
+	r.aliveOnRun = len(r.runtime.deleted) == 0
+	return r.exitCode
+}
+
+// nodeDeletingRuntime finds the nodes of a single cluster and records their
+// deletion. Other runtime calls panic.
+type nodeDeletingRuntime struct {
+	runtimes.Runtime
+	nodes   []*k3dTypes.Node
+	listErr error
+	deleted []string
+}
+
+func (r *nodeDeletingRuntime) GetNodesByLabel(_ context.Context, _ map[string]string) ([]*k3dTypes.Node, error) {
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+	return r.nodes, nil
+}
+
+func (r *nodeDeletingRuntime) GetVolumesByLabel(_ context.Context, _ map[string]string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *nodeDeletingRuntime) DeleteNode(_ context.Context, node *k3dTypes.Node) error {
+	r.deleted = append(r.deleted, node.Name)
+	return nil
+}
+
+func TestSharedK3dCluster_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		testExitCode int
+		listErr      error
+		wantExitCode int
+		wantDeleted  []string
+	}{
+		{"tests pass", 0, nil, 0, []string{"k3d-shared-server-0"}},
+		{"tests fail", 3, nil, 3, []string{"k3d-shared-server-0"}},
+		{"termination fails", 0, fmt.Errorf("runtime is gone"), 1, nil},
+		{"tests and termination fail", 3, fmt.Errorf("runtime is gone"), 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerRuntime := &nodeDeletingRuntime{
+				nodes: []*k3dTypes.Node{{
+					Name:          "k3d-shared-server-0",
+					Role:          k3dTypes.ServerRole,
+					RuntimeLabels: map[string]string{k3dTypes.LabelClusterName: "shared"},
+				}},
+				listErr: tt.listErr,
+			}
+			runner := &fakeTestRunner{exitCode: tt.testExitCode, runtime: containerRuntime}
+			shared := &SharedK3dCluster{
+				K3dCluster: &K3dCluster{
+					ClusterName:      "shared",
+					containerRuntime: containerRuntime,
+					clusterConfig:    &v1alpha5.ClusterConfig{Cluster: k3dTypes.Cluster{Name: "shared"}},
+				},
+				m: runner,
+			}
+
+			exitCode := shared.Run()
+
+			assert.Equal(t, tt.wantExitCode, exitCode)
+			assert.True(t, runner.ran)
+			assert.True(t, runner.aliveOnRun)
+			assert.Equal(t, tt.wantDeleted, containerRuntime.deleted)
+		})
+	}
+}
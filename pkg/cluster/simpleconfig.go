@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/imdario/mergo"
+	"github.com/k3d-io/k3d/v5/pkg/config"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/mitchellh/copystructure"
+	"github.com/spf13/viper"
+)
+
+// CreateK3dClusterWithConfig creates like CreateK3dCluster a completely new K8s
+// cluster but merges the given k3d configuration over the configuration that
+// testclusters-go would use otherwise. See Opts.SimpleConfig for the merge rules.
+func CreateK3dClusterWithConfig(ctx context.Context, opts Opts, simpleConfig v1alpha5.SimpleConfig) (*K3dCluster, error) {
+	opts.SimpleConfig = &simpleConfig
+	return CreateK3dCluster(ctx, opts)
+}
+
+// LoadSimpleConfig reads a k3d configuration file (like the one used with
+// `k3d cluster create --config`) so it can be used with Opts.SimpleConfig.
+func LoadSimpleConfig(path string) (v1alpha5.SimpleConfig, error) {
+	simpleConfig, _, err := loadSimpleConfigFile(path)
+	return simpleConfig, err
+}
+
+// loadSimpleConfigFile returns the configuration file also as viper instance
+// which knows which values were set explicitly.
+func loadSimpleConfigFile(path string) (v1alpha5.SimpleConfig, *viper.Viper, error) {
+	cfgViper := viper.New()
+	cfgViper.SetConfigFile(path)
+	cfgViper.SetConfigType("yaml")
+
+	err := cfgViper.ReadInConfig()
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, nil, fmt.Errorf("failed to read k3d config file %s: %w", path, err)
+	}
+
+	simpleConfig, err := config.SimpleConfigFromViper(cfgViper)
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, nil, fmt.Errorf("failed to parse k3d config file %s: %w", path, err)
+	}
+
+	return simpleConfig, cfgViper, nil
+}
+
+// overlaySimpleConfig merges the user-provided configurations from the options
+// over the default configuration. A configuration file is merged first so that
+// Opts.SimpleConfig takes precedence over the file.
+func overlaySimpleConfig(defaults v1alpha5.SimpleConfig, opts Opts) (v1alpha5.SimpleConfig, error) {
+	result := defaults
+
+	if opts.SimpleConfigFile != "" {
+		fileConfig, cfgViper, err := loadSimpleConfigFile(opts.SimpleConfigFile)
+		if err != nil {
+			return v1alpha5.SimpleConfig{}, err
+		}
+
+		result, err = mergeSimpleConfig(result, fileConfig)
+		if err != nil {
+			return v1alpha5.SimpleConfig{}, err
+		}
+
+		err = overwriteWithExplicitValues(&result, cfgViper)
+		if err != nil {
+			return v1alpha5.SimpleConfig{}, fmt.Errorf("failed to merge k3d config file %s: %w", opts.SimpleConfigFile, err)
+		}
+	}
+
+	if opts.SimpleConfig != nil {
+		var err error
+		result, err = mergeSimpleConfig(result, *opts.SimpleConfig)
+		if err != nil {
+			return v1alpha5.SimpleConfig{}, err
+		}
+	}
+
+	// neither the cluster name nor the API port may be overwritten because
+	// parallel test clusters would collide otherwise.
+	result.ObjectMeta.Name = defaults.ObjectMeta.Name
+	result.ExposeAPI.HostPort = defaults.ExposeAPI.HostPort
+	result.TypeMeta = defaults.TypeMeta
+
+	return result, nil
+}
+
+// mergeSimpleConfig merges the overlay over the base configuration. Non-empty
+// overlay values replace base values while lists of both configurations are
+// joined. The result shares no lists with the given configurations.
+func mergeSimpleConfig(base, overlay v1alpha5.SimpleConfig) (v1alpha5.SimpleConfig, error) {
+	merged, err := copySimpleConfig(overlay)
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, err
+	}
+	base, err = copySimpleConfig(base)
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, err
+	}
+
+	err = mergo.Merge(&merged, base, mergo.WithAppendSlice)
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, fmt.Errorf("failed to merge k3d configs: %w", err)
+	}
+
+	return merged, nil
+}
+
+// overwriteWithExplicitValues unmarshals the values which are set in the
+// configuration file over the merged configuration. Merging skips empty values,
+// so this lets explicit values like `wait: false` or `agents: 0` win over the
+// defaults. Lists are skipped because they were joined already.
+func overwriteWithExplicitValues(simpleConfig *v1alpha5.SimpleConfig, cfgViper *viper.Viper) error {
+	explicit := viper.New()
+	for _, key := range cfgViper.AllKeys() {
+		value := cfgViper.Get(key)
+		if _, isList := value.([]any); isList {
+			continue
+		}
+		explicit.Set(key, value)
+	}
+
+	return explicit.Unmarshal(simpleConfig)
+}
+
+func copySimpleConfig(simpleConfig v1alpha5.SimpleConfig) (v1alpha5.SimpleConfig, error) {
+	copied, err := copystructure.Copy(simpleConfig)
+	if err != nil {
+		return v1alpha5.SimpleConfig{}, fmt.Errorf("failed to copy k3d config: %w", err)
+	}
+
+	return copied.(v1alpha5.SimpleConfig), nil
+}
@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	configTypes "github.com/k3d-io/k3d/v5/pkg/config/types"
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defaultTestSimpleConfig() v1alpha5.SimpleConfig {
+	return v1alpha5.SimpleConfig{
+		TypeMeta:   configTypes.TypeMeta{Kind: "Simple", APIVersion: "k3d.io/v1alpha5"},
+		ObjectMeta: configTypes.ObjectMeta{Name: "tc-abcdef-12345678"},
+		Image:      "docker.io/rancher/k3s:" + DefaultK3sVersion,
+		Servers:    1,
+		ExposeAPI:  v1alpha5.SimpleExposureOpts{HostPort: "12345"},
+		Options: v1alpha5.SimpleConfigOptions{
+			K3sOptions: v1alpha5.SimpleConfigOptionsK3s{
+				ExtraArgs: []v1alpha5.K3sArgWithNodeFilters{{Arg: "--kubelet-arg=eviction-hard=", NodeFilters: allNodesFilter}},
+			},
+		},
+	}
+}
+
+func TestLoadSimpleConfig(t *testing.T) {
+	t.Run("should load k3d config file", func(t *testing.T) {
+		actual, err := LoadSimpleConfig("testdata/k3dSimpleConfig.yaml")
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, actual.Agents)
+		assert.Equal(t, []v1alpha5.VolumeWithNodeFilters{{Volume: "/tmp/data:/data", NodeFilters: []string{"agent:*"}}}, actual.Volumes)
+	})
+	t.Run("should fail on missing file", func(t *testing.T) {
+		_, err := LoadSimpleConfig("testdata/doesNotExist.yaml")
+
+		require.Error(t, err)
+	})
+}
+
+func Test_overlaySimpleConfig(t *testing.T) {
+	t.Run("should keep defaults without overlay", func(t *testing.T) {
+		actual, err := overlaySimpleConfig(defaultTestSimpleConfig(), Opts{})
+
+		require.NoError(t, err)
+		assert.Equal(t, defaultTestSimpleConfig(), actual)
+	})
+	t.Run("should merge overlay over defaults", func(t *testing.T) {
+		overlay := &v1alpha5.SimpleConfig{
+			ObjectMeta: configTypes.ObjectMeta{Name: "my-cluster"},
+			ExposeAPI:  v1alpha5.SimpleExposureOpts{HostPort: "6443"},
+			Image:      "my.registry/k3s:v1.27.6-k3s1",
+			Env:        []v1alpha5.EnvVarWithNodeFilters{{EnvVar: "HELLO=world", NodeFilters: []string{"server:0"}}},
+			Options: v1alpha5.SimpleConfigOptions{
+				K3sOptions: v1alpha5.SimpleConfigOptionsK3s{
+					ExtraArgs: []v1alpha5.K3sArgWithNodeFilters{{Arg: "--disable=traefik", NodeFilters: []string{"server:*"}}},
+				},
+			},
+		}
+
+		actual, err := overlaySimpleConfig(defaultTestSimpleConfig(), Opts{SimpleConfig: overlay})
+
+		require.NoError(t, err)
+		assert.Equal(t, "tc-abcdef-12345678", actual.Name)
+		assert.Equal(t, "12345", actual.ExposeAPI.HostPort)
+		assert.Equal(t, "my.registry/k3s:v1.27.6-k3s1", actual.Image)
+		assert.Equal(t, 1, actual.Servers)
+		assert.Equal(t, overlay.Env, actual.Env)
+		expectedArgs := []v1alpha5.K3sArgWithNodeFilters{
+			{Arg: "--disable=traefik", NodeFilters: []string{"server:*"}},
+			{Arg: "--kubelet-arg=eviction-hard=", NodeFilters: allNodesFilter},
+		}
+		assert.Equal(t, expectedArgs, actual.Options.K3sOptions.ExtraArgs)
+	})
+	t.Run("should merge file before overlay", func(t *testing.T) {
+		overlay := &v1alpha5.SimpleConfig{Agents: 3}
+
+		actual, err := overlaySimpleConfig(defaultTestSimpleConfig(), Opts{SimpleConfig: overlay, SimpleConfigFile: "testdata/k3dSimpleConfig.yaml"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "tc-abcdef-12345678", actual.Name)
+		assert.Equal(t, 3, actual.Agents)
+		assert.Len(t, actual.Volumes, 1)
+		assert.Len(t, actual.Options.K3sOptions.ExtraArgs, 2)
+	})
+	t.Run("should keep explicit zero values of file", func(t *testing.T) {
+		defaults := defaultTestSimpleConfig()
+		defaults.Agents = 2
+		defaults.Options.K3dOptions.Wait = true
+		defaults.Options.K3dOptions.Timeout = time.Minute
+
+		actual, err := overlaySimpleConfig(defaults, Opts{SimpleConfigFile: "testdata/k3dSimpleConfigZeroValues.yaml"})
+
+		require.NoError(t, err)
+		assert.False(t, actual.Options.K3dOptions.Wait)
+		assert.Equal(t, 0, actual.Agents)
+		assert.Equal(t, time.Minute, actual.Options.K3dOptions.Timeout)
+		assert.Equal(t, defaults.Options.K3sOptions.ExtraArgs, actual.Options.K3sOptions.ExtraArgs)
+	})
+	t.Run("should not modify lists of the given configs", func(t *testing.T) {
+		defaults := defaultTestSimpleConfig()
+		overlayArgs := make([]v1alpha5.K3sArgWithNodeFilters, 1, 4)
+		overlayArgs[0] = v1alpha5.K3sArgWithNodeFilters{Arg: "--disable=traefik", NodeFilters: []string{"server:*"}}
+		overlay := &v1alpha5.SimpleConfig{Options: v1alpha5.SimpleConfigOptions{K3sOptions: v1alpha5.SimpleConfigOptionsK3s{ExtraArgs: overlayArgs}}}
+
+		actual, err := overlaySimpleConfig(defaults, Opts{SimpleConfig: overlay})
+		require.NoError(t, err)
+		actual.Options.K3sOptions.ExtraArgs[0].NodeFilters[0] = "changed"
+		actual.Options.K3sOptions.ExtraArgs[1].NodeFilters[0] = "changed"
+
+		assert.Empty(t, overlayArgs[:2][1])
+		assert.Equal(t, "server:*", overlayArgs[0].NodeFilters[0])
+		assert.Equal(t, defaultTestSimpleConfig(), defaults)
+	})
+}
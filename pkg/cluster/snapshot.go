@@ -0,0 +1,469 @@
+package cluster
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	l "github.com/k3d-io/k3d/v5/pkg/logger"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/test-clusters/testclusters-go/pkg/naming"
+)
+
+const (
+	// k3sDatastoreDir contains k3s' embedded sqlite datastore.
+	k3sDatastoreDir  = "/var/lib/rancher/k3s/server/db"
+	k3sDatastoreFile = "state.db"
+	// datastoreFileMode matches the permissions k3s uses for its datastore.
+	datastoreFileMode     = 0600
+	serverRestartTimeout  = 2 * time.Minute
+	snapshotIDPrefix      = "snapshot"
+	etcdDatastoreDirEntry = "etcd"
+	clusterInitFlag       = "--cluster-init"
+)
+
+const (
+	// etcdSnapshotDir contains a directory for each snapshot which is saved with
+	// k3s etcd-snapshot save.
+	etcdSnapshotDir = "/var/lib/rancher/k3s/server/testclusters-go-snapshots"
+	// etcdRestoreFile is handed over to the cluster reset of the init server.
+	etcdRestoreFile = "/var/lib/rancher/k3s/server/testclusters-go-restore.db"
+	// k3sResetFlagFile is written by k3s once the cluster reset is done.
+	k3sResetFlagFile = k3sDatastoreDir + "/reset-flag"
+	// clusterResetConfigFile is read by k3s in addition to its config.yaml.
+	clusterResetConfigFile = "/etc/rancher/k3s/config.yaml.d/testclusters-go-restore.yaml"
+	clusterResetConfigMode = 0644
+	// rejoinEntrypointFile is run by the k3d entrypoint of the node before k3s
+	// starts. It wipes the etcd member of the node so that it joins the reset
+	// etcd cluster again.
+	rejoinEntrypointFile = "/bin/k3d-entrypoint-testclusters-go-rejoin.sh"
+	rejoinEntrypointMode = 0755
+	rejoinEntrypoint     = "#!/bin/sh\nrm -rf " + k3sDatastoreDir + "\nrm -f \"$0\"\n"
+)
+
+// k3sDatastoreSidecarFiles are written by sqlite next to the datastore and must
+// match the restored datastore.
+var k3sDatastoreSidecarFiles = []string{k3sDatastoreFile + "-wal", k3sDatastoreFile + "-shm"}
+
+// SnapshotID identifies a snapshot of the cluster state, see K3dCluster.Snapshot.
+type SnapshotID string
+
+// datastoreSnapshot contains either the files of the sqlite datastore or the
+// snapshot of the embedded etcd datastore.
+type datastoreSnapshot struct {
+	files map[string][]byte
+	etcd  []byte
+}
+
+// Snapshot captures the current state of all K8s resources so that the cluster
+// can be rolled back to it with Restore, f. i. to return a shared cluster to a
+// known baseline after each test.
+//
+// Clusters with a single server node use k3s' sqlite datastore. The server node
+// will be stopped while the datastore is copied, so workloads on the server node
+// restart once the node is back. Clusters with multiple server nodes use the
+// embedded etcd datastore which is saved with k3s etcd-snapshot while the
+// cluster keeps running.
+func (c *K3dCluster) Snapshot(ctx context.Context) (SnapshotID, error) {
+	server, _, err := c.snapshotServerNodes()
+	if err != nil {
+		return "", err
+	}
+
+	id := SnapshotID(naming.MustGenerateK8sName(snapshotIDPrefix))
+	var snapshot datastoreSnapshot
+	if initializesEtcd(server) {
+		snapshot.etcd, err = c.saveEtcdSnapshot(ctx, server, id)
+	} else {
+		snapshot.files, err = c.copySqliteDatastore(ctx, server)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot cluster %s: %w", c.ClusterName, err)
+	}
+
+	c.snapshotMutex.Lock()
+	if c.snapshots == nil {
+		c.snapshots = map[SnapshotID]datastoreSnapshot{}
+	}
+	c.snapshots[id] = snapshot
+	c.snapshotMutex.Unlock()
+
+	l.Log().Infof("testcluster-go: Created snapshot %s of cluster %s", id, c.ClusterName)
+	return id, nil
+}
+
+// Restore rolls the cluster back to the state of the given snapshot. All K8s
+// resources which were created after the snapshot will be gone while deleted or
+// changed resources return to their former state.
+//
+// All server nodes are stopped during the restore. For the embedded etcd
+// datastore, the init server resets the etcd cluster to the snapshot and the
+// other server nodes join it again with an empty datastore.
+func (c *K3dCluster) Restore(ctx context.Context, id SnapshotID) error {
+	c.snapshotMutex.Lock()
+	snapshot, ok := c.snapshots[id]
+	c.snapshotMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("snapshot %s not found for cluster %s", id, c.ClusterName)
+	}
+
+	server, peers, err := c.snapshotServerNodes()
+	if err != nil {
+		return err
+	}
+
+	if snapshot.etcd != nil {
+		err = c.restoreEtcdSnapshot(ctx, server, peers, snapshot.etcd)
+	} else {
+		err = c.restoreSqliteDatastore(ctx, server, snapshot.files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s of cluster %s: %w", id, c.ClusterName, err)
+	}
+
+	l.Log().Infof("testcluster-go: Restored snapshot %s of cluster %s", id, c.ClusterName)
+	return nil
+}
+
+// snapshotServerNodes returns the server node which holds the datastore and the
+// other server nodes which join the embedded etcd of the server node.
+func (c *K3dCluster) snapshotServerNodes() (server *k3dTypes.Node, peers []*k3dTypes.Node, err error) {
+	var servers []*k3dTypes.Node
+	for _, node := range c.clusterConfig.Cluster.Nodes {
+		if node.Role != k3dTypes.ServerRole {
+			continue
+		}
+		if server == nil && initializesEtcd(node) {
+			server = node
+			continue
+		}
+		servers = append(servers, node)
+	}
+
+	if server != nil {
+		return server, servers, nil
+	}
+	if len(servers) != 1 {
+		return nil, nil, fmt.Errorf("snapshots require a single server node or an init server of the embedded etcd but cluster %s has %d server nodes without %s (external datastores are not supported)", c.ClusterName, len(servers), clusterInitFlag)
+	}
+	return servers[0], nil, nil
+}
+
+// initializesEtcd returns true if the server node runs the embedded etcd which
+// the other server nodes join. k3d starts the first server node of clusters with
+// multiple server nodes this way.
+func initializesEtcd(node *k3dTypes.Node) bool {
+	if node.ServerOpts.IsInit {
+		return true
+	}
+	for _, arg := range node.Args {
+		if arg == clusterInitFlag {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *K3dCluster) copySqliteDatastore(ctx context.Context, server *k3dTypes.Node) (map[string][]byte, error) {
+	var files map[string][]byte
+	err := c.whileServersStopped(ctx, []*k3dTypes.Node{server}, func() error {
+		reader, err := c.containerRuntime.ReadFromNode(ctx, k3sDatastoreDir, server)
+		if err != nil {
+			return fmt.Errorf("failed to read datastore from node %s: %w", server.Name, err)
+		}
+		defer reader.Close()
+
+		files, err = readDatastoreFiles(reader)
+		return err
+	})
+
+	return files, err
+}
+
+func (c *K3dCluster) restoreSqliteDatastore(ctx context.Context, server *k3dTypes.Node, snapshot map[string][]byte) error {
+	return c.whileServersStopped(ctx, []*k3dTypes.Node{server}, func() error {
+		for name, content := range datastoreFilesToRestore(snapshot) {
+			dest := path.Join(k3sDatastoreDir, name)
+			err := c.containerRuntime.WriteToNode(ctx, content, dest, datastoreFileMode, server)
+			if err != nil {
+				return fmt.Errorf("failed to write %s to node %s: %w", dest, server.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// saveEtcdSnapshot saves a snapshot of the embedded etcd in a directory of its
+// own and copies the snapshot out of the server node.
+func (c *K3dCluster) saveEtcdSnapshot(ctx context.Context, server *k3dTypes.Node, id SnapshotID) ([]byte, error) {
+	dir := path.Join(etcdSnapshotDir, string(id))
+	err := c.containerRuntime.ExecInNode(ctx, server, []string{"k3s", "etcd-snapshot", "save", "--name", string(id), "--dir", dir})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save etcd snapshot on node %s: %w", server.Name, err)
+	}
+	defer func() {
+		err := c.containerRuntime.ExecInNode(ctx, server, []string{"rm", "-rf", dir})
+		if err != nil {
+			l.Log().Warnf("testcluster-go: Failed to remove etcd snapshot %s from node %s: %s", dir, server.Name, err.Error())
+		}
+	}()
+
+	reader, err := c.containerRuntime.ReadFromNode(ctx, dir, server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read etcd snapshot from node %s: %w", server.Name, err)
+	}
+	defer reader.Close()
+
+	return readEtcdSnapshot(reader)
+}
+
+// restoreEtcdSnapshot follows the k3s procedure to restore the embedded etcd:
+// The init server resets the etcd cluster to the snapshot with --cluster-reset
+// and --cluster-reset-restore-path while all server nodes are stopped. Then, the
+// init server starts normally and the other server nodes join it again after
+// their datastore was removed.
+func (c *K3dCluster) restoreEtcdSnapshot(ctx context.Context, server *k3dTypes.Node, peers []*k3dTypes.Node, snapshot []byte) error {
+	// the reset flag of a former restore would end the wait for this reset early
+	err := c.containerRuntime.ExecInNode(ctx, server, []string{"rm", "-f", k3sResetFlagFile})
+	if err != nil {
+		return fmt.Errorf("failed to remove %s from node %s: %w", k3sResetFlagFile, server.Name, err)
+	}
+
+	servers := append([]*k3dTypes.Node{server}, peers...)
+	return c.whileServersStopped(ctx, servers, func() error {
+		err := c.containerRuntime.WriteToNode(ctx, snapshot, etcdRestoreFile, datastoreFileMode, server)
+		if err != nil {
+			return fmt.Errorf("failed to write %s to node %s: %w", etcdRestoreFile, server.Name, err)
+		}
+
+		for _, peer := range peers {
+			err = c.containerRuntime.WriteToNode(ctx, []byte(rejoinEntrypoint), rejoinEntrypointFile, rejoinEntrypointMode, peer)
+			if err != nil {
+				return fmt.Errorf("failed to write %s to node %s: %w", rejoinEntrypointFile, peer.Name, err)
+			}
+		}
+
+		return c.resetEtcdCluster(ctx, server)
+	})
+}
+
+// resetEtcdCluster starts the stopped init server with a cluster reset to the
+// restore file and stops it again once the reset is done.
+func (c *K3dCluster) resetEtcdCluster(ctx context.Context, server *k3dTypes.Node) error {
+	err := c.writeClusterResetConfig(ctx, server, true)
+	if err != nil {
+		return err
+	}
+
+	err = c.containerRuntime.StartNode(ctx, server)
+	if err != nil {
+		return fmt.Errorf("failed to start node %s for the cluster reset: %w", server.Name, err)
+	}
+
+	resetErr := c.waitForClusterReset(ctx, server)
+
+	// k3s refuses to start as long as the cluster reset is configured
+	err = c.containerRuntime.StopNode(ctx, server)
+	if err != nil {
+		return errors.Join(resetErr, fmt.Errorf("failed to stop node %s after the cluster reset: %w", server.Name, err))
+	}
+	err = c.writeClusterResetConfig(ctx, server, false)
+	if err != nil {
+		return errors.Join(resetErr, err)
+	}
+
+	return resetErr
+}
+
+func (c *K3dCluster) writeClusterResetConfig(ctx context.Context, server *k3dTypes.Node, reset bool) error {
+	err := c.containerRuntime.WriteToNode(ctx, clusterResetConfig(reset), clusterResetConfigFile, clusterResetConfigMode, server)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to node %s: %w", clusterResetConfigFile, server.Name, err)
+	}
+
+	return nil
+}
+
+// clusterResetConfig returns the k3s configuration which resets the etcd
+// cluster to the restore file, or which disables the reset again.
+func clusterResetConfig(reset bool) []byte {
+	if !reset {
+		return []byte("cluster-reset: false\n")
+	}
+	return []byte("cluster-reset: true\ncluster-reset-restore-path: " + etcdRestoreFile + "\n")
+}
+
+// waitForClusterReset waits until k3s wrote its reset flag. k3s does not run
+// the cluster afterwards but waits for a restart without cluster reset.
+func (c *K3dCluster) waitForClusterReset(ctx context.Context, server *k3dTypes.Node) error {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, serverRestartTimeout, true, func(ctx context.Context) (bool, error) {
+		reader, err := c.containerRuntime.ReadFromNode(ctx, k3sResetFlagFile, server)
+		if err != nil {
+			l.Log().Debugf("testcluster-go: waiting for cluster reset on node %s: %s", server.Name, err.Error())
+			return false, nil
+		}
+		_ = reader.Close()
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waited too long for the cluster reset on node %s: %w", server.Name, err)
+	}
+
+	return nil
+}
+
+// whileServersStopped stops the server nodes so that the datastore is not being
+// written during fn, and waits until the cluster is healthy again afterwards.
+// The server nodes are started in the given order, so the server node holding
+// the datastore comes first.
+func (c *K3dCluster) whileServersStopped(ctx context.Context, servers []*k3dTypes.Node, fn func() error) error {
+	for i := len(servers) - 1; i >= 0; i-- {
+		err := c.containerRuntime.StopNode(ctx, servers[i])
+		if err != nil {
+			return fmt.Errorf("failed to stop node %s: %w", servers[i].Name, err)
+		}
+	}
+
+	fnErr := fn()
+
+	restart := time.Now()
+	for _, server := range servers {
+		err := c.containerRuntime.StartNode(ctx, server)
+		if err != nil {
+			return errors.Join(fnErr, fmt.Errorf("failed to start node %s: %w", server.Name, err))
+		}
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+
+	for _, server := range servers {
+		err := c.waitForNodeRestart(ctx, server.Name, restart)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.checkNodeHealth(ctx, NodeHealthCheckOpts{ExpectedNodes: k3sNodeCount(c.clusterConfig)})
+}
+
+// waitForNodeRestart waits until the kubelet of the given node reported its
+// status after the restart. Otherwise, the health check would accept the node
+// status from before the restart.
+func (c *K3dCluster) waitForNodeRestart(ctx context.Context, nodeName string, restart time.Time) error {
+	restart = restart.Truncate(time.Second)
+
+	err := wait.PollUntilContextTimeout(ctx, time.Second, serverRestartTimeout, true, func(ctx context.Context) (bool, error) {
+		node, err := c.clientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			l.Log().Debugf("testcluster-go: waiting for restarted node %s: %s", nodeName, err.Error())
+			return false, nil
+		}
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady {
+				return !condition.LastHeartbeatTime.Time.Before(restart), nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waited too long for node %s to restart: %w", nodeName, err)
+	}
+
+	return nil
+}
+
+// readDatastoreFiles extracts the sqlite datastore files from the tar stream of
+// the container runtime.
+func readDatastoreFiles(reader io.Reader) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read datastore archive: %w", err)
+		}
+
+		// entries are relative to the parent directory, f. i. "db/state.db"
+		name := strings.TrimPrefix(strings.TrimPrefix(header.Name, path.Base(k3sDatastoreDir)), "/")
+		if name == etcdDatastoreDirEntry || strings.HasPrefix(name, etcdDatastoreDirEntry+"/") {
+			return nil, fmt.Errorf("found an embedded etcd datastore on a server node which was not started with %s", clusterInitFlag)
+		}
+		if header.Typeflag != tar.TypeReg || strings.Contains(name, "/") {
+			continue
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read datastore file %s: %w", name, err)
+		}
+		files[name] = content
+	}
+
+	if _, ok := files[k3sDatastoreFile]; !ok {
+		return nil, fmt.Errorf("no sqlite datastore found in %s (external datastores are not supported)", k3sDatastoreDir)
+	}
+
+	return files, nil
+}
+
+// readEtcdSnapshot extracts the single etcd snapshot from the tar stream of the
+// snapshot directory. k3s names the file after the snapshot, the node and the
+// time of the snapshot.
+func readEtcdSnapshot(reader io.Reader) ([]byte, error) {
+	var snapshot []byte
+	found := 0
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd snapshot archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		found++
+		snapshot, err = io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd snapshot %s: %w", header.Name, err)
+		}
+	}
+
+	if found != 1 {
+		return nil, fmt.Errorf("expected exactly one etcd snapshot but found %d", found)
+	}
+	return snapshot, nil
+}
+
+// datastoreFilesToRestore adds empty sqlite sidecar files for those which did not
+// exist during the snapshot so that newer sidecar files are overwritten.
+func datastoreFilesToRestore(snapshot map[string][]byte) map[string][]byte {
+	files := map[string][]byte{}
+	for _, name := range k3sDatastoreSidecarFiles {
+		files[name] = []byte{}
+	}
+	for name, content := range snapshot {
+		files[name] = content
+	}
+
+	return files
+}
@@ -0,0 +1,228 @@
+package cluster
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+}
+
+func createTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	writer := tar.NewWriter(buf)
+	for _, entry := range entries {
+		err := writer.WriteHeader(&tar.Header{Name: entry.name, Typeflag: entry.typeflag, Size: int64(len(entry.content)), Mode: 0600})
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf
+}
+
+func Test_readDatastoreFiles(t *testing.T) {
+	t.Run("should read sqlite files", func(t *testing.T) {
+		archive := createTar(t,
+			tarEntry{name: "db/", typeflag: tar.TypeDir},
+			tarEntry{name: "db/state.db", typeflag: tar.TypeReg, content: "db"},
+			tarEntry{name: "db/state.db-wal", typeflag: tar.TypeReg, content: "wal"},
+			tarEntry{name: "db/sub/other", typeflag: tar.TypeReg, content: "ignored"},
+		)
+
+		files, err := readDatastoreFiles(archive)
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"state.db": []byte("db"), "state.db-wal": []byte("wal")}, files)
+	})
+	t.Run("should fail on etcd datastore", func(t *testing.T) {
+		archive := createTar(t,
+			tarEntry{name: "db/", typeflag: tar.TypeDir},
+			tarEntry{name: "db/etcd/", typeflag: tar.TypeDir},
+		)
+
+		_, err := readDatastoreFiles(archive)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "not started with --cluster-init")
+	})
+	t.Run("should fail without sqlite datastore", func(t *testing.T) {
+		archive := createTar(t, tarEntry{name: "db/", typeflag: tar.TypeDir})
+
+		_, err := readDatastoreFiles(archive)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "no sqlite datastore found")
+	})
+}
+
+func Test_datastoreFilesToRestore(t *testing.T) {
+	snapshot := map[string][]byte{"state.db": []byte("db"), "state.db-wal": []byte("wal")}
+
+	files := datastoreFilesToRestore(snapshot)
+
+	assert.Equal(t, map[string][]byte{
+		"state.db":     []byte("db"),
+		"state.db-wal": []byte("wal"),
+		"state.db-shm": {},
+	}, files)
+}
+
+func Test_readEtcdSnapshot(t *testing.T) {
+	t.Run("should read single snapshot", func(t *testing.T) {
+		archive := createTar(t,
+			tarEntry{name: "snapshot-12345678/", typeflag: tar.TypeDir},
+			tarEntry{name: "snapshot-12345678/snapshot-12345678-k3d-tcg-server-0-1697465702", typeflag: tar.TypeReg, content: "etcd"},
+		)
+
+		snapshot, err := readEtcdSnapshot(archive)
+
+		require.NoError(t, err)
+		assert.Equal(t, []byte("etcd"), snapshot)
+	})
+	t.Run("should fail without snapshot", func(t *testing.T) {
+		archive := createTar(t, tarEntry{name: "snapshot-12345678/", typeflag: tar.TypeDir})
+
+		_, err := readEtcdSnapshot(archive)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "expected exactly one etcd snapshot but found 0")
+	})
+	t.Run("should fail on multiple snapshots", func(t *testing.T) {
+		archive := createTar(t,
+			tarEntry{name: "snapshot-12345678/a", typeflag: tar.TypeReg, content: "a"},
+			tarEntry{name: "snapshot-12345678/b", typeflag: tar.TypeReg, content: "b"},
+		)
+
+		_, err := readEtcdSnapshot(archive)
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "expected exactly one etcd snapshot but found 2")
+	})
+}
+
+func Test_clusterResetConfig(t *testing.T) {
+	assert.Equal(t, "cluster-reset: true\ncluster-reset-restore-path: /var/lib/rancher/k3s/server/testclusters-go-restore.db\n", string(clusterResetConfig(true)))
+	assert.Equal(t, "cluster-reset: false\n", string(clusterResetConfig(false)))
+}
+
+func TestK3dCluster_snapshotServerNodes(t *testing.T) {
+	clusterWithNodes := func(nodes ...*k3dTypes.Node) *K3dCluster {
+		cluster := &K3dCluster{clusterConfig: &v1alpha5.ClusterConfig{}}
+		cluster.clusterConfig.Cluster.Nodes = nodes
+		return cluster
+	}
+	server := func(name string) *k3dTypes.Node {
+		return &k3dTypes.Node{Name: name, Role: k3dTypes.ServerRole}
+	}
+	initServer := &k3dTypes.Node{Name: "server-0", Role: k3dTypes.ServerRole, ServerOpts: k3dTypes.ServerOpts{IsInit: true}}
+
+	t.Run("should find single sqlite server", func(t *testing.T) {
+		node, peers, err := clusterWithNodes(server("server-0"), &k3dTypes.Node{Role: k3dTypes.AgentRole}, &k3dTypes.Node{Role: k3dTypes.LoadBalancerRole}).snapshotServerNodes()
+
+		require.NoError(t, err)
+		assert.Equal(t, "server-0", node.Name)
+		assert.False(t, initializesEtcd(node))
+		assert.Empty(t, peers)
+	})
+	t.Run("should find init server and peers of multiple servers", func(t *testing.T) {
+		node, peers, err := clusterWithNodes(server("server-1"), initServer, server("server-2"), &k3dTypes.Node{Role: k3dTypes.AgentRole}).snapshotServerNodes()
+
+		require.NoError(t, err)
+		assert.Equal(t, "server-0", node.Name)
+		assert.True(t, initializesEtcd(node))
+		require.Len(t, peers, 2)
+		assert.Equal(t, "server-1", peers[0].Name)
+		assert.Equal(t, "server-2", peers[1].Name)
+	})
+	t.Run("should find single server with cluster init flag", func(t *testing.T) {
+		node, peers, err := clusterWithNodes(&k3dTypes.Node{Name: "server-0", Role: k3dTypes.ServerRole, Args: []string{"--cluster-init"}}).snapshotServerNodes()
+
+		require.NoError(t, err)
+		assert.True(t, initializesEtcd(node))
+		assert.Empty(t, peers)
+	})
+	t.Run("should fail on multiple servers without init server", func(t *testing.T) {
+		_, _, err := clusterWithNodes(server("server-0"), server("server-1")).snapshotServerNodes()
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "has 2 server nodes without --cluster-init")
+	})
+	t.Run("should fail without servers", func(t *testing.T) {
+		_, _, err := clusterWithNodes(&k3dTypes.Node{Role: k3dTypes.AgentRole}).snapshotServerNodes()
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "has 0 server nodes")
+	})
+}
+
+// nodeRecordingRuntime records the runtime calls of snapshots. Other runtime
+// calls panic.
+type nodeRecordingRuntime struct {
+	runtimes.Runtime
+	calls []string
+	files map[string]string
+}
+
+func (r *nodeRecordingRuntime) StartNode(_ context.Context, node *k3dTypes.Node) error {
+	r.calls = append(r.calls, "start "+node.Name)
+	return nil
+}
+
+func (r *nodeRecordingRuntime) StopNode(_ context.Context, node *k3dTypes.Node) error {
+	r.calls = append(r.calls, "stop "+node.Name)
+	return nil
+}
+
+func (r *nodeRecordingRuntime) WriteToNode(_ context.Context, content []byte, dest string, _ os.FileMode, node *k3dTypes.Node) error {
+	r.calls = append(r.calls, "write "+dest+" to "+node.Name)
+	r.files[node.Name+":"+dest] = string(content)
+	return nil
+}
+
+func (r *nodeRecordingRuntime) ReadFromNode(_ context.Context, path string, node *k3dTypes.Node) (io.ReadCloser, error) {
+	r.calls = append(r.calls, "read "+path+" from "+node.Name)
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func TestK3dCluster_resetEtcdCluster(t *testing.T) {
+	containerRuntime := &nodeRecordingRuntime{files: map[string]string{}}
+	cluster := &K3dCluster{containerRuntime: containerRuntime}
+
+	err := cluster.resetEtcdCluster(testCtx, &k3dTypes.Node{Name: "server-0"})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"write /etc/rancher/k3s/config.yaml.d/testclusters-go-restore.yaml to server-0",
+		"start server-0",
+		"read /var/lib/rancher/k3s/server/db/reset-flag from server-0",
+		"stop server-0",
+		"write /etc/rancher/k3s/config.yaml.d/testclusters-go-restore.yaml to server-0",
+	}, containerRuntime.calls)
+	assert.Equal(t, "cluster-reset: false\n", containerRuntime.files["server-0:/etc/rancher/k3s/config.yaml.d/testclusters-go-restore.yaml"])
+}
+
+func TestK3dCluster_Restore(t *testing.T) {
+	t.Run("should fail on unknown snapshot", func(t *testing.T) {
+		cluster := &K3dCluster{ClusterName: "test"}
+
+		err := cluster.Restore(testCtx, "snapshot-12345678")
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "snapshot snapshot-12345678 not found")
+	})
+}
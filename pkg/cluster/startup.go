@@ -0,0 +1,189 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StartupPhase names a step of the cluster creation, see StartupReport.
+type StartupPhase string
+
+const (
+	// PhaseConfig creates the k3d cluster configuration, f. i. it finds free host ports.
+	PhaseConfig StartupPhase = "config"
+	// PhaseClusterRun creates the node containers with k3d. This includes pulling the node images and waiting
+	// for the K8s API server.
+	PhaseClusterRun StartupPhase = "cluster-run"
+	// PhaseImageImport imports Opts.PreloadImages into the cluster.
+	PhaseImageImport StartupPhase = "image-import"
+	// PhaseKubeConfig retrieves the kube config and creates the K8s clients.
+	PhaseKubeConfig StartupPhase = "kube-config"
+	// PhaseRBAC creates the admin service account and its role binding.
+	PhaseRBAC StartupPhase = "rbac"
+	// PhaseDefaultServiceAccount waits until K8s created the default service account.
+	PhaseDefaultServiceAccount StartupPhase = "default-service-account"
+	// PhaseNodeHealth waits until all nodes are ready.
+	PhaseNodeHealth StartupPhase = "node-health"
+)
+
+// startupPhases lists the phases in the order of the cluster creation.
+var startupPhases = []StartupPhase{
+	PhaseConfig,
+	PhaseClusterRun,
+	PhaseImageImport,
+	PhaseKubeConfig,
+	PhaseRBAC,
+	PhaseDefaultServiceAccount,
+	PhaseNodeHealth,
+}
+
+// PhaseDuration contains how long a startup phase took.
+type PhaseDuration struct {
+	Phase    StartupPhase
+	Duration time.Duration
+}
+
+// StartupReport contains the durations of the phases of a cluster creation so
+// that slow tests can be traced back to their cause.
+type StartupReport struct {
+	// Phases contains the durations in the order of the cluster creation.
+	Phases []PhaseDuration
+	// Total contains the duration of the whole cluster creation.
+	Total time.Duration
+}
+
+// Duration returns how long the given phase took.
+func (r StartupReport) Duration(phase StartupPhase) time.Duration {
+	for _, phaseDuration := range r.Phases {
+		if phaseDuration.Phase == phase {
+			return phaseDuration.Duration
+		}
+	}
+	return 0
+}
+
+// String returns the report in one line, f. i. "12.3s (config: 10ms, cluster-run: 9.1s, ...)".
+func (r StartupReport) String() string {
+	phases := make([]string, 0, len(r.Phases))
+	for _, phaseDuration := range r.Phases {
+		phases = append(phases, fmt.Sprintf("%s: %s", phaseDuration.Phase, roundDuration(phaseDuration.Duration)))
+	}
+
+	return fmt.Sprintf("%s (%s)", roundDuration(r.Total), strings.Join(phases, ", "))
+}
+
+// PhaseSummary aggregates the durations of a startup phase across clusters.
+type PhaseSummary struct {
+	Phase StartupPhase
+	// Total contains the summed up duration of the phase.
+	Total time.Duration
+	// Max contains the longest duration of the phase.
+	Max time.Duration
+}
+
+// StartupStats aggregates the startup reports of all clusters which were
+// created by the test binary, see StartupSummary.
+type StartupStats struct {
+	// Clusters contains the number of created clusters.
+	Clusters int
+	// Total contains the summed up creation time of all clusters.
+	Total time.Duration
+	// Phases contains the aggregated phases in the order of the cluster creation.
+	Phases []PhaseSummary
+}
+
+// String returns the summary in multiple lines with the average and maximum
+// duration of each phase.
+func (s StartupStats) String() string {
+	if s.Clusters == 0 {
+		return "no clusters were created"
+	}
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "%d clusters were created in %s (average: %s)",
+		s.Clusters, roundDuration(s.Total), roundDuration(s.Total/time.Duration(s.Clusters)))
+	for _, phase := range s.Phases {
+		_, _ = fmt.Fprintf(&b, "\n  %-24s average: %-8s max: %s",
+			phase.Phase, roundDuration(phase.Total/time.Duration(s.Clusters)), roundDuration(phase.Max))
+	}
+
+	return b.String()
+}
+
+var (
+	startupStatsMutex sync.Mutex
+	startupStats      = map[StartupPhase]*PhaseSummary{}
+	startupClusters   int
+	startupTotal      time.Duration
+)
+
+// StartupSummary aggregates the startup reports of all clusters which were
+// created by this test binary so far, f. i. to print it in TestMain after all
+// tests ran.
+func StartupSummary() StartupStats {
+	startupStatsMutex.Lock()
+	defer startupStatsMutex.Unlock()
+
+	stats := StartupStats{Clusters: startupClusters, Total: startupTotal}
+	for _, phase := range startupPhases {
+		if summary, ok := startupStats[phase]; ok {
+			stats.Phases = append(stats.Phases, *summary)
+		}
+	}
+
+	return stats
+}
+
+func recordStartup(report StartupReport) {
+	startupStatsMutex.Lock()
+	defer startupStatsMutex.Unlock()
+
+	startupClusters++
+	startupTotal += report.Total
+	for _, phaseDuration := range report.Phases {
+		summary, ok := startupStats[phaseDuration.Phase]
+		if !ok {
+			summary = &PhaseSummary{Phase: phaseDuration.Phase}
+			startupStats[phaseDuration.Phase] = summary
+		}
+
+		summary.Total += phaseDuration.Duration
+		if phaseDuration.Duration > summary.Max {
+			summary.Max = phaseDuration.Duration
+		}
+	}
+}
+
+// startupTimer measures the phases of a cluster creation one after another.
+type startupTimer struct {
+	now       func() time.Time
+	start     time.Time
+	lastPhase time.Time
+	report    StartupReport
+}
+
+func newStartupTimer(now func() time.Time) *startupTimer {
+	start := now()
+	return &startupTimer{now: now, start: start, lastPhase: start}
+}
+
+// done records the time since the previous phase as duration of the given phase.
+func (s *startupTimer) done(phase StartupPhase) {
+	now := s.now()
+	s.report.Phases = append(s.report.Phases, PhaseDuration{Phase: phase, Duration: now.Sub(s.lastPhase)})
+	s.lastPhase = now
+}
+
+func (s *startupTimer) finish() StartupReport {
+	s.report.Total = s.lastPhase.Sub(s.start)
+	return s.report
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
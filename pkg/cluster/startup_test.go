@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock advances by the given steps on every call.
+func fakeClock(steps ...time.Duration) func() time.Time {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	call := 0
+	return func() time.Time {
+		if call > 0 && call <= len(steps) {
+			now = now.Add(steps[call-1])
+		}
+		call++
+		return now
+	}
+}
+
+func Test_startupTimer(t *testing.T) {
+	timer := newStartupTimer(fakeClock(10*time.Millisecond, 9*time.Second, 2500*time.Millisecond))
+
+	timer.done(PhaseConfig)
+	timer.done(PhaseClusterRun)
+	timer.done(PhaseNodeHealth)
+	report := timer.finish()
+
+	assert.Equal(t, 11510*time.Millisecond, report.Total)
+	assert.Equal(t, 9*time.Second, report.Duration(PhaseClusterRun))
+	assert.Equal(t, time.Duration(0), report.Duration(PhaseRBAC))
+	assert.Equal(t, "11.5s (config: 10ms, cluster-run: 9s, node-health: 2.5s)", report.String())
+}
+
+func TestStartupSummary(t *testing.T) {
+	startupStatsMutex.Lock()
+	startupStats = map[StartupPhase]*PhaseSummary{}
+	startupClusters = 0
+	startupTotal = 0
+	startupStatsMutex.Unlock()
+
+	assert.Equal(t, "no clusters were created", StartupSummary().String())
+
+	recordStartup(StartupReport{Total: 10 * time.Second, Phases: []PhaseDuration{
+		{PhaseClusterRun, 8 * time.Second},
+		{PhaseNodeHealth, 2 * time.Second},
+	}})
+	recordStartup(StartupReport{Total: 20 * time.Second, Phases: []PhaseDuration{
+		{PhaseConfig, 10 * time.Millisecond},
+		{PhaseClusterRun, 12 * time.Second},
+		{PhaseNodeHealth, 7990 * time.Millisecond},
+	}})
+
+	actual := StartupSummary()
+
+	assert.Equal(t, 2, actual.Clusters)
+	assert.Equal(t, 30*time.Second, actual.Total)
+	assert.Equal(t, []PhaseSummary{
+		{Phase: PhaseConfig, Total: 10 * time.Millisecond, Max: 10 * time.Millisecond},
+		{Phase: PhaseClusterRun, Total: 20 * time.Second, Max: 12 * time.Second},
+		{Phase: PhaseNodeHealth, Total: 9990 * time.Millisecond, Max: 7990 * time.Millisecond},
+	}, actual.Phases)
+	assert.Equal(t, "2 clusters were created in 30s (average: 15s)\n"+
+		"  config                   average: 5ms      max: 10ms\n"+
+		"  cluster-run              average: 10s      max: 12s\n"+
+		"  node-health              average: 5s       max: 8s", actual.String())
+}
@@ -0,0 +1,83 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// ApplyTemplate renders the Go text/template with the given values and applies
+// each rendered document. Values which are missing from maps fail the rendering
+// instead of rendering "<no value>". This happens before functions like default
+// see the value, so keys which may be missing must be accessed with index, f. i.
+// {{ index . "Replicas" | default 1 }}. Besides the built-in template functions,
+// the sprig functions and Helm's required and toYaml are available like in Helm
+// charts. Errors show the rendered document which could not be applied.
+func (ya *YamlApplier) ApplyTemplate(ctx context.Context, tmpl []byte, values any) error {
+	rendered, err := renderTemplate(tmpl, values)
+	if err != nil {
+		return err
+	}
+
+	docs, err := splitYamlDocuments(rendered)
+	if err != nil {
+		return fmt.Errorf("failed to read rendered template: %w\n%s", err, string(rendered))
+	}
+
+	for i, doc := range docs {
+		err = ya.ApplyWithFile(ctx, doc)
+		if err != nil {
+			return fmt.Errorf("failed to apply rendered document %d: %w\n%s", i+1, err, string(doc))
+		}
+	}
+
+	return nil
+}
+
+func renderTemplate(tmpl []byte, values any) ([]byte, error) {
+	parsed, err := template.New("manifest").Option("missingkey=error").Funcs(templateFuncs()).Parse(string(tmpl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	err = parsed.Execute(&rendered, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	return rendered.Bytes(), nil
+}
+
+// templateFuncs returns the sprig functions which Helm charts use as well, plus
+// Helm's required and toYaml.
+func templateFuncs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["required"] = required
+	funcs["toYaml"] = toYaml
+	return funcs
+}
+
+func required(msg string, value any) (any, error) {
+	if value == nil {
+		return nil, errors.New(msg)
+	}
+	if text, ok := value.(string); ok && text == "" {
+		return nil, errors.New(msg)
+	}
+	return value, nil
+}
+
+func toYaml(value any) (string, error) {
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %v to YAML: %w", value, err)
+	}
+	return strings.TrimSuffix(string(content), "\n"), nil
+}
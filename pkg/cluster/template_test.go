@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_renderTemplate(t *testing.T) {
+	values := map[string]any{
+		"Name":     "nginx",
+		"Replicas": 0,
+		"Image":    "",
+		"Labels":   map[string]string{"app": "nginx", "tier": "web"},
+	}
+
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{"renders values", "name: {{ .Name }}", "name: nginx", ""},
+		{"uses default for empty value", "replicas: {{ .Replicas | default 2 }}", "replicas: 2", ""},
+		{"quotes and upper-cases", "app: {{ .Name | upper | quote }}", `app: "NGINX"`, ""},
+		{"indents YAML", "labels:{{ .Labels | toYaml | nindent 2 }}", "labels:\n  app: nginx\n  tier: web", ""},
+		{"encodes base64", "password: {{ b64enc \"secret\" }}", "password: c2VjcmV0", ""},
+		{"uses default for missing key with index", "tag: {{ index . \"Tag\" | default \"latest\" }}", "tag: latest", ""},
+		{"uses sprig functions", "name: {{ .Name | trunc 3 | title }}", "name: Ngi", ""},
+		{"fails on missing key", "tag: {{ .Tag }}", "", `map has no entry for key "Tag"`},
+		{"fails on missing key before default", "tag: {{ .Tag | default \"latest\" }}", "", `map has no entry for key "Tag"`},
+		{"fails on required empty value", "image: {{ required \"image must be set\" .Image }}", "", "image must be set"},
+		{"fails on invalid template", "name: {{ .Name", "", "failed to parse template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := renderTemplate([]byte(tt.tmpl), values)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(actual))
+		})
+	}
+}
+
+const configMapTemplate = `{{ range .Names }}---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ . }}
+data:
+  image: {{ $.Image }}
+{{ end }}`
+
+func TestYamlApplier_ApplyTemplate(t *testing.T) {
+	t.Run("should apply all rendered documents", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyTemplate(testCtx, []byte(configMapTemplate), map[string]any{"Names": []string{"first", "second"}, "Image": "nginx:1.25"})
+
+		require.NoError(t, err)
+		for _, name := range []string{"first", "second"} {
+			actual, err := cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "nginx:1.25", actual.Data["image"])
+		}
+	})
+	t.Run("should show rendered document on failure", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.ApplyTemplate(testCtx, []byte("apiVersion: v1\nkind: {{ .Kind }}\nmetadata: [{{ .Name }}\n"), map[string]any{"Kind": "ConfigMap", "Name": "broken"})
+
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "failed to apply rendered document 1")
+		assert.ErrorContains(t, err, "kind: ConfigMap\nmetadata: [broken")
+	})
+}
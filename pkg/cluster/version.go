@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	k3dTypes "github.com/k3d-io/k3d/v5/pkg/types"
+)
+
+// DefaultK3sVersion is used when no K3s version was configured.
+const DefaultK3sVersion = K3sVersion1_28
+
+// KnownK3sVersions returns all K3s versions that testclusters-go was tested with.
+func KnownK3sVersions() []string {
+	return []string{K3sVersion1_26, K3sVersion1_28}
+}
+
+// k3sImage returns the image reference for the given K3s version. The version
+// may be one of the known K3s versions or a custom image reference which
+// contains at least a tag or digest.
+func k3sImage(version string) (string, error) {
+	if version == "" {
+		version = DefaultK3sVersion
+	}
+
+	if isCustomImage(version) {
+		return version, nil
+	}
+
+	// k3s versions are tagged with a `+` separator but the images use `-`.
+	version = strings.Replace(version, "+", "-", 1)
+	for _, known := range KnownK3sVersions() {
+		if version == known {
+			return fmt.Sprintf("%s:%s", k3dTypes.DefaultK3sImageRepo, version), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown k3s version '%s' (known versions: %s; custom images must contain a tag like 'rancher/k3s:v1.27.6-k3s1')",
+		version, strings.Join(KnownK3sVersions(), ", "))
+}
+
+// isCustomImage checks whether the version is an image reference with a tag or
+// a digest. Colons before the last slash belong to the registry port, f. i. in
+// "my.registry:5000/k3s".
+func isCustomImage(version string) bool {
+	name := version[strings.LastIndex(version, "/")+1:]
+	return strings.ContainsAny(name, ":@")
+}
+
+// ForEachVersion runs the given test function as sub-test for each of the given
+// K3s versions. Every sub-test receives its own cluster of the respective
+// version. If no versions are given, all known K3s versions will be tested.
+func ForEachVersion(t *testing.T, versions []string, testFunc func(t *testing.T, cl *K3dCluster)) {
+	ForEachVersionWithOpts(t, Opts{}, versions, testFunc)
+}
+
+// ForEachVersionWithOpts works like ForEachVersion but with more control over
+// the cluster customization. Opts.K3sVersion will be replaced by each version.
+func ForEachVersionWithOpts(t *testing.T, opts Opts, versions []string, testFunc func(t *testing.T, cl *K3dCluster)) {
+	t.Helper()
+
+	for _, run := range versionRuns(opts, versions) {
+		run := run
+		t.Run(run.name, func(t *testing.T) {
+			cl := NewK3dClusterWithOpts(t, run.opts)
+			testFunc(t, cl)
+		})
+	}
+}
+
+// versionRun contains the sub-test name and the cluster options of a single
+// version of ForEachVersionWithOpts.
+type versionRun struct {
+	name string
+	opts Opts
+}
+
+// versionRuns returns a sub-test for each version, or for each known version if
+// no versions are given.
+func versionRuns(opts Opts, versions []string) []versionRun {
+	if len(versions) == 0 {
+		versions = KnownK3sVersions()
+	}
+
+	runs := make([]versionRun, 0, len(versions))
+	for _, version := range versions {
+		versionOpts := opts
+		versionOpts.K3sVersion = version
+		runs = append(runs, versionRun{name: version, opts: versionOpts})
+	}
+
+	return runs
+}
@@ -0,0 +1,80 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_k3sImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"empty version leads to default", "", "docker.io/rancher/k3s:" + DefaultK3sVersion, false},
+		{"known version", K3sVersion1_26, "docker.io/rancher/k3s:v1.26.2-k3s1", false},
+		{"known version with k3s tag separator", "v1.28.2+k3s1", "docker.io/rancher/k3s:v1.28.2-k3s1", false},
+		{"custom image", "my.registry/k3s:v1.27.6-k3s1", "my.registry/k3s:v1.27.6-k3s1", false},
+		{"custom image by digest", "rancher/k3s@sha256:abcdef", "rancher/k3s@sha256:abcdef", false},
+		{"custom image from registry with port", "my.registry:5000/k3s:v1.27.6-k3s1", "my.registry:5000/k3s:v1.27.6-k3s1", false},
+		{"custom image without tag", "my.registry:5000/k3s", "", true},
+		{"unknown version", "v1.12.0-k3s1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k3sImage(tt.version)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_versionRuns(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Opts
+		versions []string
+		want     []versionRun
+	}{
+		{
+			"all known versions without versions",
+			Opts{},
+			nil,
+			[]versionRun{
+				{name: K3sVersion1_26, opts: Opts{K3sVersion: K3sVersion1_26}},
+				{name: K3sVersion1_28, opts: Opts{K3sVersion: K3sVersion1_28}},
+			},
+		},
+		{
+			"given versions in order",
+			Opts{},
+			[]string{K3sVersion1_28, "my.registry:5000/k3s:v1.27.6-k3s1"},
+			[]versionRun{
+				{name: K3sVersion1_28, opts: Opts{K3sVersion: K3sVersion1_28}},
+				{name: "my.registry:5000/k3s:v1.27.6-k3s1", opts: Opts{K3sVersion: "my.registry:5000/k3s:v1.27.6-k3s1"}},
+			},
+		},
+		{
+			"version replaces option and keeps other options",
+			Opts{K3sVersion: K3sVersion1_26, ClusterNamePrefix: "versions", Agents: 2},
+			[]string{K3sVersion1_28},
+			[]versionRun{
+				{name: K3sVersion1_28, opts: Opts{K3sVersion: K3sVersion1_28, ClusterNamePrefix: "versions", Agents: 2}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := versionRuns(tt.opts, tt.versions)
+
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
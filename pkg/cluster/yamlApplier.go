@@ -2,9 +2,21 @@ package cluster
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 
 	"github.com/cloudogu/k8s-apply-lib/apply"
 )
@@ -16,7 +28,10 @@ type kubeApplier interface {
 // YamlApplier provides a pod with kubectl access to the cluster.
 type YamlApplier struct {
 	applier          kubeApplier
+	deleter          kubeDeleter
 	defaultNamespace string
+	// applied contains the documents which will be deleted on test cleanup, see WithCleanup.
+	applied *appliedDocuments
 }
 
 func NewYamlApplier(restConfig *rest.Config, fieldManager, defaultNamespace string) (*YamlApplier, error) {
@@ -24,14 +39,207 @@ func NewYamlApplier(restConfig *rest.Config, fieldManager, defaultNamespace stri
 	if err != nil {
 		return nil, fmt.Errorf("could not create applier: %w", err)
 	}
-	return &YamlApplier{applier: applier, defaultNamespace: defaultNamespace}, nil
+
+	deleter, err := newDynamicDeleter(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not create deleter: %w", err)
+	}
+
+	return &YamlApplier{applier: applier, deleter: deleter, defaultNamespace: defaultNamespace}, nil
+}
+
+func newDynamicDeleter(restConfig *rest.Config) (*dynamicDeleter, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	return &dynamicDeleter{client: dynamicClient, resourceOf: restResourceMapper(mapper), pollInterval: time.Second}, nil
 }
 
 // ApplyWithFile copies the yaml bytes into a file and calls `kubectl apply -f`.
 func (ya *YamlApplier) ApplyWithFile(ctx context.Context, yamlBytes []byte) error {
-	err := ya.applier.Apply(yamlBytes, ya.defaultNamespace)
+	return ya.applyInNamespace(yamlBytes, ya.defaultNamespace)
+}
+
+// applyInNamespace applies namespaced resources into the given namespace
+// instead of the namespace of the YamlApplier.
+func (ya *YamlApplier) applyInNamespace(yamlBytes []byte, namespace string) error {
+	err := ya.applier.Apply(yamlBytes, namespace)
 	if err != nil {
 		return err
 	}
+
+	if ya.applied != nil {
+		// the applier only applies the first document
+		docs, err := splitYamlDocuments(yamlBytes)
+		if err == nil && len(docs) > 0 {
+			ya.applied.add(appliedDocument{doc: docs[0], namespace: namespace})
+		}
+	}
 	return nil
 }
+
+// ApplyFS applies the YAML files of the file system, f. i. an embed.FS, which
+// match the patterns. Patterns follow fs.Glob. Matching directories are walked
+// recursively for files with the extension .yaml or .yml. Without patterns the
+// whole file system is applied. Files are applied in lexical order, each
+// document of a file after another. Errors name the file and the document,
+// counted from 1.
+func (ya *YamlApplier) ApplyFS(ctx context.Context, fsys fs.FS, patterns ...string) error {
+	files, err := yamlFiles(fsys, patterns)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		docs, err := splitYamlDocuments(content)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		for i, doc := range docs {
+			err = ya.ApplyWithFile(ctx, doc)
+			if err != nil {
+				return fmt.Errorf("failed to apply document %d of %s: %w", i+1, file, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// yamlFiles resolves the patterns into a sorted list of files without duplicates.
+func yamlFiles(fsys fs.FS, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	found := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %s matches no files", pattern)
+		}
+
+		for _, match := range matches {
+			err = fs.WalkDir(fsys, match, func(file string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				// explicitly matched files are applied regardless of their extension
+				if file == match && !entry.IsDir() || isYamlFile(file, entry) {
+					found[file] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to walk %s: %w", match, err)
+			}
+		}
+	}
+
+	files := make([]string, 0, len(found))
+	for file := range found {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func isYamlFile(file string, entry fs.DirEntry) bool {
+	ext := strings.ToLower(path.Ext(file))
+	return !entry.IsDir() && (ext == ".yaml" || ext == ".yml")
+}
+
+// DeleteWithFile deletes the resources of all documents in the yaml bytes in
+// reverse order and waits until they are gone. By default, dependent resources
+// (f. i. the pods of a deployment) are deleted first, see WithPropagationPolicy.
+// Resources which do not exist are ignored. DeleteWithFile tries to delete all
+// documents and returns all errors.
+func (ya *YamlApplier) DeleteWithFile(ctx context.Context, yamlBytes []byte, opts ...DeleteOption) error {
+	docs, err := splitYamlDocuments(yamlBytes)
+	if err != nil {
+		return err
+	}
+
+	applied := make([]appliedDocument, 0, len(docs))
+	for _, doc := range docs {
+		applied = append(applied, appliedDocument{doc: doc, namespace: ya.defaultNamespace})
+	}
+
+	return ya.deleteDocuments(ctx, applied, newDeleteOptions(opts))
+}
+
+func (ya *YamlApplier) deleteDocuments(ctx context.Context, docs []appliedDocument, options deleteOptions) error {
+	var errs []error
+	for i := len(docs) - 1; i >= 0; i-- {
+		errs = append(errs, ya.deleter.Delete(ctx, docs[i].doc, docs[i].namespace, options.propagation))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithCleanup returns a YamlApplier which remembers all resources it applies and
+// deletes them in reverse order once the test finishes. This keeps shared
+// clusters clean.
+func (ya *YamlApplier) WithCleanup(t testing.TB) *YamlApplier {
+	t.Helper()
+
+	tracking := &YamlApplier{
+		applier:          ya.applier,
+		deleter:          ya.deleter,
+		defaultNamespace: ya.defaultNamespace,
+		applied:          &appliedDocuments{},
+	}
+
+	t.Cleanup(func() {
+		err := tracking.deleteDocuments(context.Background(), tracking.applied.list(), newDeleteOptions(nil))
+		if err != nil {
+			t.Errorf("testcluster-go: Unexpected error while deleting applied resources: %s", err.Error())
+		}
+	})
+
+	return tracking
+}
+
+// appliedDocument is a document together with the namespace it was applied into.
+type appliedDocument struct {
+	doc       apply.YamlDocument
+	namespace string
+}
+
+// appliedDocuments records applied documents in the order of their application.
+type appliedDocuments struct {
+	mutex sync.Mutex
+	docs  []appliedDocument
+}
+
+func (a *appliedDocuments) add(doc appliedDocument) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.docs = append(a.docs, doc)
+}
+
+func (a *appliedDocuments) list() []appliedDocument {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]appliedDocument{}, a.docs...)
+}
@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/cloudogu/k8s-apply-lib/apply"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	k8sTesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+)
+
+const namespaceAndConfigMapYaml = `# the namespace comes first
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: my-namespace
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+data:
+  key: value
+---
+# nothing to see here
+`
+
+func Test_splitYamlDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want int
+	}{
+		{"empty", "", 0},
+		{"single document", fmt.Sprintf(configMapYaml, "value"), 1},
+		{"leading separator", "---\n" + fmt.Sprintf(configMapYaml, "value"), 1},
+		{"skips comment-only documents", namespaceAndConfigMapYaml, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := splitYamlDocuments([]byte(tt.yaml))
+
+			require.NoError(t, err)
+			assert.Len(t, actual, tt.want)
+		})
+	}
+}
+
+func deletedNames(cl *FakeCluster) []string {
+	var names []string
+	for _, action := range cl.dynamicClient.Actions() {
+		if deleteAction, ok := action.(k8sTesting.DeleteAction); ok {
+			names = append(names, deleteAction.GetName())
+		}
+	}
+	return names
+}
+
+// recordingDynamicClient records the delete options because the fake dynamic
+// client drops them.
+type recordingDynamicClient struct {
+	dynamic.Interface
+	propagations *[]metav1.DeletionPropagation
+}
+
+func (c recordingDynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return recordingResource{NamespaceableResourceInterface: c.Interface.Resource(gvr), propagations: c.propagations}
+}
+
+type recordingResource struct {
+	dynamic.NamespaceableResourceInterface
+	propagations *[]metav1.DeletionPropagation
+}
+
+func (r recordingResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return recordingNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), propagations: r.propagations}
+}
+
+func (r recordingResource) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	*r.propagations = append(*r.propagations, *opts.PropagationPolicy)
+	return r.NamespaceableResourceInterface.Delete(ctx, name, opts, subresources...)
+}
+
+type recordingNamespacedResource struct {
+	dynamic.ResourceInterface
+	propagations *[]metav1.DeletionPropagation
+}
+
+func (r recordingNamespacedResource) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	*r.propagations = append(*r.propagations, *opts.PropagationPolicy)
+	return r.ResourceInterface.Delete(ctx, name, opts, subresources...)
+}
+
+func recordingKubectl(t *testing.T, propagations *[]metav1.DeletionPropagation) *YamlApplier {
+	cl := NewFakeCluster(t)
+	kubectl, err := cl.CtlKube("test")
+	require.NoError(t, err)
+
+	kubectl.deleter = &dynamicDeleter{
+		client:       recordingDynamicClient{Interface: cl.dynamicClient, propagations: propagations},
+		resourceOf:   cl.resourceOf,
+		pollInterval: time.Millisecond,
+	}
+	return kubectl
+}
+
+func TestYamlApplier_DeleteWithFile(t *testing.T) {
+	t.Run("should delete all documents in reverse order", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+		require.NoError(t, kubectl.ApplyWithFile(testCtx, []byte(fmt.Sprintf(configMapYaml, "value"))))
+
+		err = kubectl.DeleteWithFile(testCtx, []byte(namespaceAndConfigMapYaml))
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"my-config", "my-namespace"}, deletedNames(cl))
+		_, err = cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "my-config", metav1.GetOptions{})
+		assert.True(t, k8sErrs.IsNotFound(err))
+	})
+	t.Run("should delete in the foreground by default", func(t *testing.T) {
+		var propagations []metav1.DeletionPropagation
+		kubectl := recordingKubectl(t, &propagations)
+
+		err := kubectl.DeleteWithFile(testCtx, []byte(namespaceAndConfigMapYaml))
+
+		require.NoError(t, err)
+		assert.Equal(t, []metav1.DeletionPropagation{metav1.DeletePropagationForeground, metav1.DeletePropagationForeground}, propagations)
+	})
+	t.Run("should delete with selected propagation policy", func(t *testing.T) {
+		var propagations []metav1.DeletionPropagation
+		kubectl := recordingKubectl(t, &propagations)
+
+		err := kubectl.DeleteWithFile(testCtx, []byte(namespaceAndConfigMapYaml), WithPropagationPolicy(metav1.DeletePropagationBackground))
+
+		require.NoError(t, err)
+		assert.Equal(t, []metav1.DeletionPropagation{metav1.DeletePropagationBackground, metav1.DeletePropagationBackground}, propagations)
+	})
+	t.Run("should fail on invalid yaml", func(t *testing.T) {
+		cl := NewFakeCluster(t)
+		kubectl, err := cl.CtlKube("test")
+		require.NoError(t, err)
+
+		err = kubectl.DeleteWithFile(testCtx, []byte("kind: [\n"))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestYamlApplier_WithCleanup(t *testing.T) {
+	cl := NewFakeCluster(t)
+	kubectl, err := cl.CtlKube("test")
+	require.NoError(t, err)
+
+	t.Run("test which applies resources", func(t *testing.T) {
+		tracking := kubectl.WithCleanup(t)
+		require.NoError(t, tracking.ApplyWithFile(testCtx, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: my-namespace\n")))
+		require.NoError(t, tracking.ApplyWithFile(testCtx, []byte(fmt.Sprintf(configMapYaml, "value"))))
+		// untracked resources remain
+		require.NoError(t, kubectl.ApplyWithFile(testCtx, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: other\n")))
+	})
+
+	assert.Equal(t, []string{"my-config", "my-namespace"}, deletedNames(cl))
+	_, err = cl.FakeClientSet().CoreV1().ConfigMaps(DefaultNamespace).Get(testCtx, "other", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+// recordingApplier records the names of successfully applied resources.
+type recordingApplier struct {
+	kubeApplier
+	names []string
+}
+
+func (r *recordingApplier) Apply(yamlResource apply.YamlDocument, namespace string) error {
+	err := r.kubeApplier.Apply(yamlResource, namespace)
+	if err != nil {
+		return err
+	}
+
+	obj := map[string]interface{}{}
+	_ = yaml.Unmarshal(yamlResource, &obj)
+	r.names = append(r.names, obj["metadata"].(map[string]interface{})["name"].(string))
+	return nil
+}
+
+func TestYamlApplier_ApplyFS(t *testing.T) {
+	configMap := func(name string) []byte {
+		return []byte(fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name))
+	}
+	fsys := fstest.MapFS{
+		"manifests/b.yaml":          {Data: configMap("b")},
+		"manifests/a.yml":           {Data: append(append(configMap("a-1"), "---\n"...), configMap("a-2")...)},
+		"manifests/README.md":       {Data: []byte("# not a manifest")},
+		"manifests/nested/c.yaml":   {Data: configMap("c")},
+		"other/d.yaml":              {Data: configMap("d")},
+		"broken/ok.yaml":            {Data: configMap("ok")},
+		"broken/second-is-bad.yaml": {Data: append(append(configMap("first"), "---\n"...), "kind: [\n"...)},
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  string
+	}{
+		{"walks directories in lexical order", []string{"manifests"}, []string{"a-1", "a-2", "b", "c"}, ""},
+		{"applies globs without duplicates", []string{"*/d.yaml", "other", "manifests/b.yaml"}, []string{"b", "d"}, ""},
+		{"names failing file and document", []string{"broken"}, []string{"ok", "first"}, "failed to apply document 2 of broken/second-is-bad.yaml"},
+		{"fails on pattern without match", []string{"missing/*.yaml"}, nil, "pattern missing/*.yaml matches no files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applier := &recordingApplier{kubeApplier: &fakeApplier{cluster: NewFakeCluster(t)}}
+			kubectl := &YamlApplier{applier: applier, defaultNamespace: DefaultNamespace}
+
+			err := kubectl.ApplyFS(testCtx, fsys, tt.patterns...)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, applier.names)
+		})
+	}
+}
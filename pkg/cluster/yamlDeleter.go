@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cloudogu/k8s-apply-lib/apply"
+	k8sErrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sYaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+const resourceDeletionTimeout = 2 * time.Minute
+
+type kubeDeleter interface {
+	Delete(ctx context.Context, yamlResource apply.YamlDocument, namespace string, propagation metav1.DeletionPropagation) error
+}
+
+// DeleteOption customizes the deletion of YAML resources, see YamlApplier.DeleteWithFile.
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	propagation metav1.DeletionPropagation
+}
+
+func newDeleteOptions(opts []DeleteOption) deleteOptions {
+	options := deleteOptions{propagation: metav1.DeletePropagationForeground}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithPropagationPolicy selects how the dependents of deleted resources are
+// deleted, f. i. metav1.DeletePropagationBackground to not wait for them or
+// metav1.DeletePropagationOrphan to keep them.
+// Defaults to metav1.DeletePropagationForeground.
+func WithPropagationPolicy(policy metav1.DeletionPropagation) DeleteOption {
+	return func(options *deleteOptions) {
+		options.propagation = policy
+	}
+}
+
+// resourceMapper maps a kind to its resource and tells whether the resource is namespaced.
+type resourceMapper func(gvk schema.GroupVersionKind) (schema.GroupVersionResource, bool, error)
+
+func restResourceMapper(mapper meta.RESTMapper) resourceMapper {
+	return func(gvk schema.GroupVersionKind) (schema.GroupVersionResource, bool, error) {
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return schema.GroupVersionResource{}, false, err
+		}
+		return mapping.Resource, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+	}
+}
+
+// dynamicDeleter deletes YAML resources with a dynamic client and waits until
+// they are gone.
+type dynamicDeleter struct {
+	client       dynamic.Interface
+	resourceOf   resourceMapper
+	pollInterval time.Duration
+}
+
+// Delete deletes the resource of the YAML document with the given propagation
+// policy for its dependents. Like the applier, the namespace replaces the
+// document's namespace of namespaced resources. Resources which do not exist are
+// ignored.
+func (d *dynamicDeleter) Delete(ctx context.Context, yamlResource apply.YamlDocument, namespace string, propagation metav1.DeletionPropagation) error {
+	obj := &unstructured.Unstructured{}
+	_, gvk, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(yamlResource, nil, obj)
+	if err != nil {
+		return fmt.Errorf("could not decode YAML document '%s': %w", string(yamlResource), err)
+	}
+
+	gvr, namespaced, err := d.resourceOf(*gvk)
+	if err != nil {
+		return fmt.Errorf("could not find resource of %s: %w", gvk.String(), err)
+	}
+
+	var resource dynamic.ResourceInterface = d.client.Resource(gvr)
+	if namespaced {
+		resource = d.client.Resource(gvr).Namespace(namespace)
+	}
+
+	err = resource.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		if k8sErrs.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, d.pollInterval, resourceDeletionTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if k8sErrs.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("waited too long for %s %s to be deleted: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// splitYamlDocuments splits a multi-document YAML file into its documents.
+// Documents without content, f. i. only comments, are skipped.
+func splitYamlDocuments(yamlBytes []byte) ([]apply.YamlDocument, error) {
+	reader := k8sYaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(yamlBytes)))
+
+	var docs []apply.YamlDocument
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to split YAML documents: %w", err)
+		}
+
+		if hasYamlContent(doc) {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+func hasYamlContent(doc []byte) bool {
+	for _, line := range bytes.Split(doc, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' && !bytes.Equal(line, []byte("---")) {
+			return true
+		}
+	}
+	return false
+}